@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/archive"
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+)
+
+// runImport registers the items of an instagram data export in the sync DB.
+// They are published by the daemon once archive publishing is enabled.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configFile := fs.String("config", "./configs/config.yaml", "Configuration file path")
+	archivePath := fs.String("archive", "", "Instagram data export ZIP path (defaults to archive.path from config)")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	path := *archivePath
+	if path == "" {
+		path = cfg.Archive.Path
+	}
+	if path == "" {
+		log.Fatalf("No archive to import, pass -archive or set archive.path")
+	}
+
+	database, err := db.SetupDB(cfg.Database.DSN)
+	if err != nil {
+		log.Fatalf("Failed to setup database: %v", err)
+	}
+	defer database.Close()
+
+	added, err := archive.Import(path, cfg.Archive.Stories, database)
+	if err != nil {
+		log.Fatalf("Failed to import archive: %v", err)
+	}
+
+	log.Printf("[import] Registered %d new items from %s\n", added, path)
+}
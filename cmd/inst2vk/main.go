@@ -5,19 +5,34 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"github.com/s-yakubovskiy/inst2vk/pkg/archive"
 	"github.com/s-yakubovskiy/inst2vk/pkg/config"
 	"github.com/s-yakubovskiy/inst2vk/pkg/daemon"
 	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/feed"
+	"github.com/s-yakubovskiy/inst2vk/pkg/folder"
 	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/mastodon"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/server"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
 	"github.com/s-yakubovskiy/inst2vk/pkg/storage"
+	"github.com/s-yakubovskiy/inst2vk/pkg/telegram"
 	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
 	// parse flags
 	configFile := flag.String("config", "./configs/config.yaml", "Configuration file path")
 	flag.Parse()
@@ -47,17 +62,124 @@ func main() {
 
 	// Setup vk apiClient
 	vkClient := vk.NewClient(cfg.VK)
+	tokenInfo, err := vkClient.Validate()
+	if err != nil {
+		log.Fatalf("Failed to validate VK token: %v", err)
+	}
+	log.Printf("VK token of %s, scopes: %s", tokenInfo.Name, strings.Join(tokenInfo.Scopes, ","))
+
+	// Setup publishers, sync profiles pick the destinations of each source
+	publishers := map[string]publish.Publisher{
+		vk.Destination: vk.NewPublisher(cfg, vkClient, database),
+	}
+	if cfg.Telegram.ChatID != "" {
+		publishers[telegram.Destination] = telegram.NewPublisher(telegram.NewClient(cfg.Telegram))
+	}
+	if cfg.Mastodon.Instance != "" {
+		mastodonPublisher, err := mastodon.NewPublisher(mastodon.NewClient(cfg.Mastodon), cfg.Mastodon)
+		if err != nil {
+			log.Fatalf("Failed to setup mastodon publisher: %v", err)
+		}
+		publishers[mastodon.Destination] = mastodonPublisher
+	}
+	allPublishers := make([]publish.Publisher, 0, len(publishers))
+	for _, p := range publishers {
+		allPublishers = append(allPublishers, p)
+	}
+	profile := func(kind source.Kind) []publish.Publisher {
+		names, ok := cfg.Profiles[string(kind)]
+		if !ok {
+			names = []string{vk.Destination}
+		}
+		var profile []publish.Publisher
+		for _, name := range names {
+			p, ok := publishers[name]
+			if !ok {
+				log.Fatalf("Unknown destination %q in %s profile", name, kind)
+			}
+			profile = append(profile, p)
+		}
+		return profile
+	}
 
 	// Create the Daemon
-	mediaWorker := daemon.NewMediaWorker(cfg, database, gcsClient, metaClient, vkClient)
-	storyWorker := daemon.NewStoryWorker(cfg, database, gcsClient, metaClient, vkClient)
+	mediaSource := instagram.NewSource(metaClient, source.KindMedia)
+	storySource := instagram.NewSource(metaClient, source.KindStories)
+	newMediaWorker := func(src source.Source) *daemon.MediaWorker {
+		worker, err := daemon.NewMediaWorker(cfg, database, gcsClient, src, profile(src.Kind()))
+		if err != nil {
+			log.Fatalf("Failed to setup %s worker: %v", src.Kind(), err)
+		}
+		return worker
+	}
+	mediaWorker := newMediaWorker(mediaSource)
+	storyWorker, err := daemon.NewStoryWorker(cfg, database, gcsClient, storySource, profile(source.KindStories))
+	if err != nil {
+		log.Fatalf("Failed to setup story worker: %v", err)
+	}
+
+	workers := []daemon.Worker{mediaWorker, storyWorker}
+	if cfg.Instagram.Tags.Enabled {
+		tagsSource := instagram.NewSource(metaClient, source.KindTags)
+		tagsWorker, err := daemon.NewTagsWorker(cfg, database, gcsClient, tagsSource, profile(source.KindTags))
+		if err != nil {
+			log.Fatalf("Failed to setup tags worker: %v", err)
+		}
+		workers = append(workers, tagsWorker)
+	}
+	if cfg.Insights.Enabled {
+		workers = append(workers, daemon.NewInsightsWorker(cfg, database, metaClient, vkClient))
+	}
+	if cfg.Comments.Enabled {
+		commentsWorker, err := daemon.NewCommentsWorker(cfg, database, metaClient, vkClient)
+		if err != nil {
+			log.Fatalf("Failed to setup comments worker: %v", err)
+		}
+		workers = append(workers, commentsWorker)
+	}
+	if cfg.Captions.Enabled {
+		workers = append(workers, daemon.NewCaptionWorker(cfg, database, metaClient, allPublishers))
+	}
+	if cfg.Reconcile.Enabled {
+		reconcileWorker, err := daemon.NewReconcileWorker(cfg, database, metaClient, vkClient, allPublishers)
+		if err != nil {
+			log.Fatalf("Failed to setup reconcile worker: %v", err)
+		}
+		workers = append(workers, reconcileWorker)
+	}
+	if cfg.Folder.Enabled {
+		folderSource := folder.NewSource(cfg.Folder)
+		workers = append(workers, newMediaWorker(folderSource))
+	}
+	if cfg.Archive.Enabled {
+		archiveSource := archive.NewSource(cfg.Archive, database)
+		workers = append(workers, newMediaWorker(archiveSource))
+	}
+	if cfg.Feed.Enabled {
+		feedSource := feed.NewSource(cfg.Feed)
+		workers = append(workers, newMediaWorker(feedSource))
+	}
+
+	// Serve uploads from the daemon, the VK rate limit is per process
+	if cfg.Server.Enabled {
+		// set default fallback values
+		addr := cfg.Server.Addr
+		if addr == "" {
+			addr = ":8080"
+		}
+		mux := server.NewServer(vk.NewVideoService(vkClient, tokenInfo))
+		go func() {
+			log.Printf("Starting server at %s", addr)
+			log.Fatal(http.ListenAndServe(addr, mux))
+		}()
+	}
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // ensure all paths cancel the context to avoid context leak
 
 	// Create Daemon and start
-	daemon := daemon.NewDaemon(mediaWorker, storyWorker)
+	daemon := daemon.NewDaemon(workers...)
 	daemon.Start(ctx)
 
 	// Handle SIGINT and SIGTERM.
@@ -18,7 +18,11 @@ func main() {
 	}
 
 	vkClient := vk.NewClient(cfg.VK)
-	vkService := &vk.VideoService{Client: vkClient}
+	tokenInfo, err := vkClient.Validate()
+	if err != nil {
+		log.Fatalf("Failed to validate VK token: %v", err)
+	}
+	vkService := vk.NewVideoService(vkClient, tokenInfo)
 
 	mux := server.NewServer(vkService)
 
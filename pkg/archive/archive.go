@@ -0,0 +1,187 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// exportMedia is a media entry of the "Download your information" export.
+type exportMedia struct {
+	URI               string `json:"uri"`
+	CreationTimestamp int64  `json:"creation_timestamp"`
+	Title             string `json:"title"`
+}
+
+type exportPost struct {
+	Media             []exportMedia `json:"media"`
+	Title             string        `json:"title"`
+	CreationTimestamp int64         `json:"creation_timestamp"`
+}
+
+type exportReels struct {
+	Reels []exportPost `json:"ig_reels_media"`
+}
+
+type exportStories struct {
+	Stories []exportMedia `json:"ig_stories"`
+}
+
+// Read parses posts and reels of the instagram data export ZIP, and stories
+// too if asked to. Every post becomes an item, a post of several media a
+// carousel of them. Item media urls are paths of the files inside the
+// archive.
+func Read(zipPath string, stories bool) ([]*source.Item, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var items []*source.Item
+	for _, f := range r.File {
+		if path.Base(path.Dir(f.Name)) != "content" {
+			continue
+		}
+
+		var posts []exportPost
+		base := path.Base(f.Name)
+		switch {
+		case strings.HasPrefix(base, "posts_") && path.Ext(base) == ".json":
+			err = decode(f, &posts)
+		case base == "reels.json":
+			var reels exportReels
+			err = decode(f, &reels)
+			posts = reels.Reels
+		case base == "stories.json" && stories:
+			var archived exportStories
+			err = decode(f, &archived)
+			for _, m := range archived.Stories {
+				posts = append(posts, exportPost{Media: []exportMedia{m}})
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, post := range posts {
+			if item := postItem(post); item != nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	source.SortItems(items)
+	return items, nil
+}
+
+func decode(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return json.NewDecoder(rc).Decode(v)
+}
+
+// postItem returns the item of the post, nil if it has no media to publish.
+// The item id is derived from the first media, so it stays the same for
+// posts of a single media.
+func postItem(post exportPost) *source.Item {
+	var media []*source.Item
+	for _, m := range post.Media {
+		mediaType := source.MediaTypeOf(m.URI)
+		if mediaType == "" {
+			continue
+		}
+
+		timestamp := m.CreationTimestamp
+		if timestamp == 0 {
+			timestamp = post.CreationTimestamp
+		}
+		media = append(media, &source.Item{
+			ID:        ItemID(m.URI),
+			Caption:   fixEncoding(m.Title),
+			MediaType: mediaType,
+			MediaURL:  m.URI,
+			Timestamp: time.Unix(timestamp, 0),
+		})
+	}
+	if len(media) == 0 {
+		return nil
+	}
+
+	item := *media[0]
+	if post.Title != "" {
+		item.Caption = fixEncoding(post.Title)
+	}
+	if post.CreationTimestamp != 0 {
+		item.Timestamp = time.Unix(post.CreationTimestamp, 0)
+	}
+	if len(media) > 1 {
+		item.MediaType = source.MediaTypeCarousel
+		item.Children = media
+	}
+	return &item
+}
+
+// ItemID derives the item id from the path of the media in the archive.
+func ItemID(uri string) string {
+	sum := sha1.Sum([]byte(uri))
+	return hex.EncodeToString(sum[:])
+}
+
+// fixEncoding repairs export strings, which are UTF-8 bytes escaped one by
+// one as latin-1 code points.
+func fixEncoding(value string) string {
+	b := make([]byte, 0, len(value))
+	for _, r := range value {
+		if r > 0xff {
+			return value
+		}
+		b = append(b, byte(r))
+	}
+	if !utf8.Valid(b) {
+		return value
+	}
+	return string(b)
+}
+
+// open returns a reader of the file inside the archive. Closing it closes
+// the archive too.
+func open(zipPath, name string) (io.ReadCloser, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := r.Open(name)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &archiveFile{File: f, archive: r}, nil
+}
+
+type archiveFile struct {
+	fs.File
+	archive *zip.ReadCloser
+}
+
+func (f *archiveFile) Close() error {
+	f.File.Close()
+	return f.archive.Close()
+}
@@ -0,0 +1,130 @@
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// writeExport writes a small data export ZIP with the given files.
+func writeExport(t *testing.T, files map[string]string) string {
+	t.Helper()
+	zipPath := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func TestRead(t *testing.T) {
+	zipPath := writeExport(t, map[string]string{
+		// "café" as the export escapes it, UTF-8 bytes as latin-1 code points
+		"your_instagram_activity/content/posts_1.json": `[
+			{"media": [{"uri": "media/posts/a.jpg", "creation_timestamp": 100, "title": "cafÃ©"}]},
+			{"title": "carousel", "creation_timestamp": 300, "media": [
+				{"uri": "media/posts/b.jpg", "creation_timestamp": 300},
+				{"uri": "media/posts/c.mp4", "creation_timestamp": 300},
+				{"uri": "media/posts/notes.txt", "creation_timestamp": 300}
+			]}
+		]`,
+		"your_instagram_activity/content/reels.json": `{"ig_reels_media": [
+			{"media": [{"uri": "media/reels/d.mp4", "creation_timestamp": 200, "title": "reel"}]}
+		]}`,
+		"your_instagram_activity/content/stories.json": `{"ig_stories": [
+			{"uri": "media/stories/e.jpg", "creation_timestamp": 50, "title": "story"}
+		]}`,
+		"media/posts/a.jpg": "a",
+	})
+
+	items, err := Read(zipPath, false)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Read returned %d items, want 3", len(items))
+	}
+
+	tests := []struct {
+		uri       string
+		caption   string
+		mediaType string
+		timestamp int64
+		children  []string
+	}{
+		{"media/posts/a.jpg", "café", source.MediaTypeImage, 100, nil},
+		{"media/reels/d.mp4", "reel", source.MediaTypeVideo, 200, nil},
+		{"media/posts/b.jpg", "carousel", source.MediaTypeCarousel, 300, []string{"media/posts/b.jpg", "media/posts/c.mp4"}},
+	}
+	for i, tt := range tests {
+		item := items[i]
+		if item.ID != ItemID(tt.uri) || item.MediaURL != tt.uri {
+			t.Errorf("item %d = %s %s, want %s", i, item.ID, item.MediaURL, tt.uri)
+		}
+		if item.Caption != tt.caption || item.MediaType != tt.mediaType || !item.Timestamp.Equal(time.Unix(tt.timestamp, 0)) {
+			t.Errorf("item %d = %q %s %s, want %q %s %d", i, item.Caption, item.MediaType, item.Timestamp, tt.caption, tt.mediaType, tt.timestamp)
+		}
+		if len(item.Children) != len(tt.children) {
+			t.Errorf("item %d has %d children, want %d", i, len(item.Children), len(tt.children))
+			continue
+		}
+		for j, child := range item.Children {
+			if child.MediaURL != tt.children[j] || child.ID != ItemID(tt.children[j]) {
+				t.Errorf("item %d child %d = %s, want %s", i, j, child.MediaURL, tt.children[j])
+			}
+		}
+	}
+
+	withStories, err := Read(zipPath, true)
+	if err != nil {
+		t.Fatalf("Read with stories: %v", err)
+	}
+	if len(withStories) != 4 || withStories[0].MediaURL != "media/stories/e.jpg" {
+		t.Errorf("Read with stories returned %d items, want the story first of 4", len(withStories))
+	}
+
+	rc, err := open(zipPath, "media/posts/a.jpg")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	rc.Close()
+}
+
+func TestFixEncoding(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"cafÃ©", "café"},
+		{"ð\u009f\u0098\u0080", "😀"},
+		// not UTF-8 once turned into bytes, kept as is
+		{"café", "café"},
+		// already decoded text is kept as is
+		{"привет", "привет"},
+	}
+	for _, tt := range tests {
+		if got := fixEncoding(tt.value); got != tt.want {
+			t.Errorf("fixEncoding(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
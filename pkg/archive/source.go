@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Source implements the source.Source interface.
+var _ source.Source = (*Source)(nil)
+
+// Source feeds items registered by the import command from the sync DB,
+// a batch per interval, and reads their content from the export archive.
+type Source struct {
+	database *sql.DB
+	path     string
+	perCycle int
+	interval time.Duration
+	stories  bool
+	// listed is when the last batch was handed out
+	listed time.Time
+}
+
+func NewSource(config config.ArchiveConfig, database *sql.DB) *Source {
+	// set default fallback values
+	if config.PerCycle == 0 {
+		config.PerCycle = 1
+	}
+	if config.Interval == 0 {
+		config.Interval = 3600
+	}
+
+	return &Source{
+		database: database,
+		path:     config.Path,
+		perCycle: config.PerCycle,
+		interval: time.Duration(config.Interval) * time.Second,
+		stories:  config.Stories,
+	}
+}
+
+func (s *Source) Kind() source.Kind {
+	return source.KindArchive
+}
+
+// List returns the oldest registered items that are not published yet. It
+// returns an empty page until the interval since the last batch passed.
+func (s *Source) List(ctx context.Context, cursor string) (*source.Page, error) {
+	if time.Since(s.listed) < s.interval {
+		return &source.Page{}, nil
+	}
+
+	items, err := db.PendingItems(string(source.KindArchive), s.perCycle, s.database)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &source.Page{}, nil
+	}
+	s.listed = time.Now()
+
+	// the sync DB keeps no carousel parts, they are read from the archive
+	archived, err := Read(s.path, s.stories)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*source.Item, len(archived))
+	for _, item := range archived {
+		byID[item.ID] = item
+	}
+	for i, item := range items {
+		if full, ok := byID[item.ID]; ok {
+			items[i] = full
+		}
+	}
+
+	return &source.Page{Items: items}, nil
+}
+
+func (s *Source) Detail(ctx context.Context, id string) (*source.Item, error) {
+	items, err := Read(s.path, s.stories)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("archive item %s not found", id)
+}
+
+func (s *Source) Download(ctx context.Context, item *source.Item) (io.ReadCloser, error) {
+	return open(s.path, item.MediaURL)
+}
+
+// Import registers the items of the archive in the sync DB and returns how
+// many of them are new. Stories are only registered if asked to.
+func Import(zipPath string, stories bool, database *sql.DB) (int, error) {
+	items, err := Read(zipPath, stories)
+	if err != nil {
+		return 0, err
+	}
+
+	var added int
+	for _, item := range items {
+		ok, err := db.RegisterItem(item, string(source.KindArchive), database)
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added++
+		}
+	}
+
+	return added, nil
+}
@@ -8,23 +8,277 @@ import (
 
 type Config struct {
 	Instagram     InstagramConfig `yaml:"instagram"`
+	Stories       StoriesConfig   `yaml:"stories"`
 	VK            VKConfig        `yaml:"vk"`
+	Telegram      TelegramConfig  `yaml:"telegram"`
+	Mastodon      MastodonConfig  `yaml:"mastodon"`
 	Database      DatabaseConfig  `yaml:"database"`
 	GCS           GCSConfig       `yaml:"gcs"`
+	Insights      InsightsConfig  `yaml:"insights"`
+	Comments      CommentsConfig  `yaml:"comments"`
+	Captions      CaptionsConfig  `yaml:"captions"`
+	Reconcile     ReconcileConfig `yaml:"reconcile"`
+	Folder        FolderConfig    `yaml:"folder"`
+	Feed          FeedConfig      `yaml:"feed"`
+	Archive       ArchiveConfig   `yaml:"archive"`
+	Server        ServerConfig    `yaml:"server"`
 	SleepInterval int64           `yaml:"sleep_interval"`
+	// MaxAttempts is how often an item is tried before it is marked failed
+	// and stops holding back the items after it, 5 by default
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// Profiles map source kinds (media, stories, tags, folder, feed,
+	// archive) to the destinations their items are published to, vk by
+	// default
+	Profiles map[string][]string `yaml:"profiles"`
+	// StoryProfiles set the audience of stories per sync profile, keyed by
+	// source kind like Profiles
+	StoryProfiles map[string]StoryProfileConfig `yaml:"story_profiles"`
+}
+
+// StoryProfileConfig is the audience of the stories of a sync profile.
+type StoryProfileConfig struct {
+	// ReplyToStory makes the stories replies to a story, e.g. 1_2
+	ReplyToStory string `yaml:"reply_to_story"`
+	// UserIDs limits who can see the stories, everyone when empty
+	UserIDs []int `yaml:"user_ids"`
+}
+
+// TelegramConfig sets up publishing to a Telegram channel or chat through
+// the Bot API.
+type TelegramConfig struct {
+	// BotToken of the bot, the bot must be an admin allowed to post in the
+	// chat
+	BotToken string `yaml:"bot_token"`
+	// ChatID is the numeric chat id or the @username of a public channel
+	ChatID string `yaml:"chat_id"`
+	// API is the Bot API base url, defaults to https://api.telegram.org
+	API string `yaml:"api"`
+	// DisableNotification sends posts silently
+	DisableNotification bool `yaml:"disable_notification"`
+}
+
+// MastodonConfig sets up publishing to a Mastodon account.
+type MastodonConfig struct {
+	// Instance is the base url of the instance, e.g. https://mastodon.social
+	Instance    string `yaml:"instance"`
+	AccessToken string `yaml:"access_token"`
+	// Visibility of statuses: public (default), unlisted, private or direct
+	Visibility string `yaml:"visibility"`
+	// SpoilerText is the content warning shown instead of the status until
+	// expanded, none when empty
+	SpoilerText string `yaml:"spoiler_text"`
+	// Sensitive hides media behind a warning
+	Sensitive bool `yaml:"sensitive"`
+	// MaxCharacters is the status length limit of the instance, 500 by
+	// default
+	MaxCharacters int `yaml:"max_characters"`
+	// ProcessingTimeout bounds the wait for the instance to process uploaded
+	// media in seconds, 300 by default
+	ProcessingTimeout int64 `yaml:"processing_timeout"`
 }
 
 type InstagramConfig struct {
-	AccessToken      string `yaml:"access_token"`
-	AccountID        string `yaml:"account_id"`
-	API              string `yaml:"api"`
-	LastPostsCount   int    `yaml:"last_posts_count"`
-	LastStoriesCount int    `yaml:"last_stories_count"`
+	AccessToken      string     `yaml:"access_token"`
+	AccountID        string     `yaml:"account_id"`
+	API              string     `yaml:"api"`
+	LastPostsCount   int        `yaml:"last_posts_count"`
+	LastStoriesCount int        `yaml:"last_stories_count"`
+	Tags             TagsConfig `yaml:"tags"`
+}
+
+// StoriesConfig controls the StoryWorker, which runs on its own cadence since
+// stories expire after 24h.
+type StoriesConfig struct {
+	// Interval between polls in seconds, defaults to sleep_interval
+	Interval int64 `yaml:"interval"`
+	// DeleteRemoved deletes the VK story when the instagram story is
+	// removed before it expires
+	DeleteRemoved bool `yaml:"delete_removed"`
+	// LinkText is the VK link button text, e.g. "more", "open" or "go_to"
+	LinkText string `yaml:"link_text"`
+	// LinkURL is a text/template of the link button url rendered with the
+	// story (.ID, .Permalink, .Username, .Shortcode), no button when empty
+	LinkURL string `yaml:"link_url"`
+}
+
+// TagsConfig controls reposting of media our account is tagged in.
+type TagsConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	LastTagsCount    int      `yaml:"last_tags_count"`
+	AllowedUsernames []string `yaml:"allowed_usernames"`
+	// CaptionTemplate is a text/template rendered with the tagged media
+	// (.Caption, .Username, .Permalink).
+	CaptionTemplate string `yaml:"caption_template"`
 }
 
 type VKConfig struct {
+	// AccessToken is a user token or, for community publishing, a
+	// community token
 	AccessToken string `yaml:"access_token"`
-	OwnerID     int    `yaml:"owner_id"`
+	// OwnerID is the user id, a negative id publishes to that community
+	OwnerID int `yaml:"owner_id"`
+	// GroupID publishes to the community wall, videos and stories
+	GroupID int `yaml:"group_id"`
+	// FromGroup posts on behalf of the community instead of the user,
+	// defaults to true
+	FromGroup *bool `yaml:"from_group"`
+	// Signed adds the author signature to community posts
+	Signed   bool           `yaml:"signed"`
+	Schedule ScheduleConfig `yaml:"schedule"`
+	// Albums place uploaded media into VK albums, the first matching rule
+	// wins
+	Albums []AlbumRule `yaml:"albums"`
+	Video  VideoConfig `yaml:"video"`
+	// RequestsPerSecond limits VK API requests of the token, defaults to 3
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+}
+
+// VideoConfig holds the video.save options of uploaded videos.
+type VideoConfig struct {
+	// PrivacyView and PrivacyComment are VK privacy values, e.g. all,
+	// friends or only_me
+	PrivacyView    []string `yaml:"privacy_view"`
+	PrivacyComment []string `yaml:"privacy_comment"`
+	NoComments     bool     `yaml:"no_comments"`
+	// Repeat, Compression and Wallpost default to true
+	Repeat      *bool `yaml:"repeat"`
+	Compression *bool `yaml:"compression"`
+	// Wallpost also publishes the video on the wall
+	Wallpost *bool `yaml:"wallpost"`
+	// Cover sets the instagram thumbnail, or a frame extracted with ffmpeg
+	// when there is none, as the video cover. Defaults to true
+	Cover *bool `yaml:"cover"`
+	// FFmpegPath defaults to ffmpeg from PATH
+	FFmpegPath string `yaml:"ffmpeg_path"`
+	// ProcessingTimeout in seconds to wait for VK to process an uploaded
+	// video, defaults to 120. A video still processing after it is checked
+	// again on the next cycle
+	ProcessingTimeout int64 `yaml:"processing_timeout"`
+	// MaxProcessingWait in seconds bounds the total wait for VK to process
+	// a video over all cycles, defaults to 10800. The video is deleted after
+	// it and the item counts a failed attempt
+	MaxProcessingWait int64 `yaml:"max_processing_wait"`
+}
+
+// AlbumRule maps media to VK albums. A rule without media_type and hashtag
+// matches everything, which puts all media into a fixed album.
+type AlbumRule struct {
+	// MediaType matches the instagram media type, e.g. VIDEO or IMAGE
+	MediaType string `yaml:"media_type"`
+	// Hashtag matches captions containing the hashtag, e.g. #travel
+	Hashtag string `yaml:"hashtag"`
+	// VideoAlbum and PhotoAlbum are album titles, missing albums are
+	// created on first use
+	VideoAlbum string `yaml:"video_album"`
+	PhotoAlbum string `yaml:"photo_album"`
+}
+
+// ScheduleConfig spreads VK posts over time with postponed posts instead of
+// publishing everything the moment it is seen. Videos are postponed as wall
+// posts, so it can't be combined with a disabled video wallpost.
+type ScheduleConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Timezone of the posting hours, e.g. Europe/Moscow
+	Timezone string `yaml:"timezone"`
+	// StartHour and EndHour bound the posting hours, [start, end) within one
+	// day, windows over midnight are not supported
+	StartHour int `yaml:"start_hour"`
+	EndHour   int `yaml:"end_hour"`
+	// MinGap between posts in seconds
+	MinGap int64 `yaml:"min_gap"`
+	// MaxPerDay limits posts per day, 0 means no limit
+	MaxPerDay int `yaml:"max_per_day"`
+}
+
+// InsightsConfig controls periodic collection of engagement metrics for
+// synced media on both platforms.
+type InsightsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval between collections in seconds
+	Interval int64 `yaml:"interval"`
+	// MaxAgeDays limits collection to media created in the last days
+	MaxAgeDays int `yaml:"max_age_days"`
+}
+
+// CommentsConfig controls mirroring of instagram comments into VK.
+type CommentsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval between polls in seconds
+	Interval int64 `yaml:"interval"`
+	// MaxAgeDays limits mirroring to media created in the last days
+	MaxAgeDays int `yaml:"max_age_days"`
+	// Anonymize is one of "none" (full username), "mask" (first and last
+	// letters only) or "hide" (no username at all)
+	Anonymize string `yaml:"anonymize"`
+	// Template is a text/template rendered with the comment (.Author, .Text)
+	Template string `yaml:"template"`
+}
+
+// CaptionsConfig controls propagation of instagram caption edits to VK.
+type CaptionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval between checks in seconds
+	Interval int64 `yaml:"interval"`
+	// MaxAgeDays limits checks to media created in the last days
+	MaxAgeDays int `yaml:"max_age_days"`
+}
+
+// ReconcileConfig controls propagation of instagram deletions to VK.
+type ReconcileConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval between passes in seconds
+	Interval int64 `yaml:"interval"`
+	// MaxAgeDays limits checks to media created in the last days
+	MaxAgeDays int `yaml:"max_age_days"`
+	// Policy is one of "delete", "archive" or "flag"
+	Policy string `yaml:"policy"`
+	// GracePeriod in seconds media has to stay missing before the policy
+	// is applied
+	GracePeriod int64 `yaml:"grace_period"`
+	// DryRun only logs what would be done
+	DryRun bool `yaml:"dry_run"`
+}
+
+// FolderConfig controls publishing of media files dropped into a local
+// directory. Files are published in modification time order, files that
+// show up with an older time are published as well.
+type FolderConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// SettleTime in seconds a file must stay unmodified before it is picked
+	// up, so files still being copied are skipped
+	SettleTime int64 `yaml:"settle_time"`
+}
+
+// FeedConfig controls cross-posting of RSS and Atom feed entries.
+type FeedConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	URLs             []string `yaml:"urls"`
+	LastEntriesCount int      `yaml:"last_entries_count"`
+}
+
+// ServerConfig controls serving the vk_server endpoints from the daemon, so
+// uploads share the VK rate limit of the workers.
+type ServerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Addr to listen on, :8080 by default
+	Addr string `yaml:"addr"`
+}
+
+// ArchiveConfig controls publishing of items imported from an instagram data
+// export.
+type ArchiveConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Path of the export ZIP the items were imported from
+	Path string `yaml:"path"`
+	// PerCycle is how many items are published at once
+	PerCycle int `yaml:"per_cycle"`
+	// Interval between publishing batches in seconds
+	Interval int64 `yaml:"interval"`
+	// Stories imports archived stories too, they are published as wall
+	// posts like the rest, off by default
+	Stories bool `yaml:"stories"`
 }
 
 type DatabaseConfig struct {
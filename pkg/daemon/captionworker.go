@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// batchSize is the maximum number of ids graph api accepts in one lookup.
+const batchSize = 50
+
+// CaptionWorker re-checks captions of recently synced media and applies
+// instagram edits to their published copies.
+type CaptionWorker struct {
+	cfg        *config.Config
+	database   *sql.DB
+	metaClient *instagram.Client
+	publishers []publish.Publisher
+}
+
+func NewCaptionWorker(cfg *config.Config, database *sql.DB, metaClient *instagram.Client, publishers []publish.Publisher) *CaptionWorker {
+	// set default fallback values
+	if cfg.Captions.Interval == 0 {
+		cfg.Captions.Interval = 900
+	}
+	if cfg.Captions.MaxAgeDays == 0 {
+		cfg.Captions.MaxAgeDays = 7
+	}
+
+	return &CaptionWorker{
+		cfg:        cfg,
+		database:   database,
+		metaClient: metaClient,
+		publishers: publishers,
+	}
+}
+
+func (m *CaptionWorker) Work(ctx context.Context) {
+	log.Printf("[worker:captions] CaptionWorker run")
+	for {
+		select {
+		case <-ctx.Done():
+			// Context was cancelled, stop the worker
+			return
+		default:
+			m.checkCaptions(ctx)
+			// Sleep for the configured duration before the next check
+			select {
+			case <-time.After(time.Duration(m.cfg.Captions.Interval) * time.Second):
+			case <-ctx.Done():
+				// If context is cancelled, stop sleeping and return
+				return
+			}
+			break
+		}
+	}
+}
+
+func (d *CaptionWorker) checkCaptions(ctx context.Context) {
+	since := time.Now().AddDate(0, 0, -d.cfg.Captions.MaxAgeDays)
+	records, err := db.SyncedRecords(string(source.KindMedia), since, d.database)
+	if err != nil {
+		log.Printf("[worker:captions:db] Failed to get synced records: %v", err)
+		return
+	}
+
+	for start := 0; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[start:end]
+
+		details := d.fetchDetails(ctx, batch)
+		for _, r := range batch {
+			media, ok := details[r.ID]
+			if !ok {
+				continue
+			}
+			d.syncCaption(ctx, r, media.Caption)
+		}
+	}
+}
+
+// fetchDetails looks up the records in one batch and falls back to single
+// lookups when the batch fails, e.g. because one of the media was deleted.
+func (d *CaptionWorker) fetchDetails(ctx context.Context, records []db.Record) map[string]instagram.MediaDetail {
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+
+	details, err := d.metaClient.FetchMediaDetails(ctx, ids)
+	if err == nil {
+		return details
+	}
+	log.Printf("[worker:captions] Batch lookup failed, falling back to single lookups: %v", err)
+
+	details = make(map[string]instagram.MediaDetail)
+	for _, id := range ids {
+		media, err := d.metaClient.FetchMediaDetail(ctx, id)
+		if err != nil {
+			log.Printf("[worker:captions] Failed to fetch media details for %s: %v", id, err)
+			continue
+		}
+		details[id] = *media
+	}
+	return details
+}
+
+func (d *CaptionWorker) syncCaption(ctx context.Context, r db.Record, caption string) {
+	hash := db.CaptionHash(caption)
+	if hash == r.CaptionHash {
+		return
+	}
+
+	// media synced before hashes were stored only get a baseline
+	if r.CaptionHash != "" {
+		if !d.editCaptions(ctx, r.ID, caption) {
+			return
+		}
+		log.Printf("[worker:captions:inst2vk] Updated caption of media id: %s\n", r.ID)
+	}
+
+	err := db.UpdateCaptionHash(r.ID, string(source.KindMedia), hash, d.database)
+	if err != nil {
+		log.Printf("[worker:captions:db] Failed to update caption hash for %s: %v", r.ID, err)
+	}
+}
+
+// editCaptions edits the caption of every published copy of the media. The
+// hash is only updated once all copies are edited, so failed edits are
+// retried; edits are idempotent.
+func (d *CaptionWorker) editCaptions(ctx context.Context, id, caption string) bool {
+	deliveries, err := db.Deliveries(id, string(source.KindMedia), d.database)
+	if err != nil {
+		log.Printf("[worker:captions:db] Failed to get deliveries of %s: %v", id, err)
+		return false
+	}
+
+	ok := true
+	for _, delivery := range deliveries {
+		if delivery.Ref == "" {
+			continue
+		}
+		p := publisherFor(d.publishers, delivery.Destination)
+		if p == nil || !p.Capabilities().Edit {
+			continue
+		}
+		if publisherPaused("worker:captions", p) {
+			ok = false
+			continue
+		}
+
+		// edits don't know the media of the post, the text limit applies and
+		// publishers cut captions of media further
+		err := p.Edit(ctx, publish.Ref(delivery.Ref), publish.Truncate(caption, p.Capabilities().CaptionLimit(nil)))
+		if errors.Is(err, publish.ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[worker:captions] Failed to edit %s caption for %s: %v\n", p.Name(), id, err)
+			alertVKError("worker:captions", err)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
+)
+
+const defaultCommentTemplate = "{{.Author}} (Instagram): {{.Text}}"
+
+// Anonymization modes of mirrored comment authors.
+const (
+	AnonymizeNone = "none"
+	AnonymizeMask = "mask"
+	AnonymizeHide = "hide"
+)
+
+// CommentsWorker mirrors new instagram comments of synced media as comments
+// under the corresponding VK post or video.
+type CommentsWorker struct {
+	cfg        *config.Config
+	database   *sql.DB
+	metaClient *instagram.Client
+	vkClient   *vk.Client
+	template   *template.Template
+}
+
+func NewCommentsWorker(cfg *config.Config, database *sql.DB, metaClient *instagram.Client, vkClient *vk.Client) (*CommentsWorker, error) {
+	// set default fallback values
+	if cfg.Comments.Interval == 0 {
+		cfg.Comments.Interval = 300
+	}
+	if cfg.Comments.MaxAgeDays == 0 {
+		cfg.Comments.MaxAgeDays = 7
+	}
+
+	switch cfg.Comments.Anonymize {
+	case "":
+		cfg.Comments.Anonymize = AnonymizeNone
+	case AnonymizeNone, AnonymizeMask, AnonymizeHide:
+	default:
+		return nil, fmt.Errorf("unknown comments anonymize mode: %s", cfg.Comments.Anonymize)
+	}
+
+	text := cfg.Comments.Template
+	if text == "" {
+		text = defaultCommentTemplate
+	}
+	tmpl, err := template.New("comment").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommentsWorker{
+		cfg:        cfg,
+		database:   database,
+		metaClient: metaClient,
+		vkClient:   vkClient,
+		template:   tmpl,
+	}, nil
+}
+
+func (m *CommentsWorker) Work(ctx context.Context) {
+	log.Printf("[worker:comments] CommentsWorker run")
+	for {
+		select {
+		case <-ctx.Done():
+			// Context was cancelled, stop the worker
+			return
+		default:
+			m.mirror(ctx)
+			// Sleep for the configured duration before checking for new comments
+			select {
+			case <-time.After(time.Duration(m.cfg.Comments.Interval) * time.Second):
+			case <-ctx.Done():
+				// If context is cancelled, stop sleeping and return
+				return
+			}
+			break
+		}
+	}
+}
+
+func (d *CommentsWorker) mirror(ctx context.Context) {
+	if vkPaused("worker:comments", d.vkClient) {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -d.cfg.Comments.MaxAgeDays)
+	records, err := db.SyncedRecords(string(source.KindMedia), since, d.database)
+	if err != nil {
+		log.Printf("[worker:comments:db] Failed to get synced records: %v", err)
+		return
+	}
+
+	for _, r := range records {
+		if r.VKType != vk.ObjectWall && r.VKType != vk.ObjectVideo {
+			continue
+		}
+
+		comments, err := d.metaClient.FetchComments(ctx, r.ID)
+		if err != nil {
+			log.Printf("[worker:comments] Failed to fetch comments for %s: %v", r.ID, err)
+			continue
+		}
+
+		for _, comment := range comments {
+			if !d.mirrorComment(r, comment) {
+				break
+			}
+		}
+	}
+}
+
+func (d *CommentsWorker) mirrorComment(r db.Record, comment instagram.Comment) bool {
+	mirrored, err := db.IsCommentMirrored(comment.ID, d.database)
+	if err != nil {
+		log.Printf("[worker:comments:db] Failed to check comment %s: %v", comment.ID, err)
+		return false
+	}
+	if mirrored {
+		return true
+	}
+
+	message, err := d.render(comment)
+	if err != nil {
+		log.Printf("[worker:comments] Failed to render comment %s: %v", comment.ID, err)
+		return false
+	}
+
+	commentID, err := d.vkClient.CreateComment(vk.Object{Type: r.VKType, OwnerID: r.VKOwnerID, ID: r.VKID}, message)
+	if err != nil {
+		log.Printf("[worker:comments] Failed to post vk comment for %s: %v\n", comment.ID, err)
+		alertVKError("worker:comments", err)
+		return false
+	}
+
+	err = db.SaveComment(comment.ID, r.ID, commentID, d.database)
+	if err != nil {
+		log.Printf("[worker:comments:db] Failed to save mirrored comment %s: %v", comment.ID, err)
+		return false
+	}
+
+	log.Printf("[worker:comments:inst2vk] Mirrored comment %s of media %s\n", comment.ID, r.ID)
+	return true
+}
+
+// render formats the comment according to the template and anonymize mode.
+func (d *CommentsWorker) render(comment instagram.Comment) (string, error) {
+	data := struct {
+		Author string
+		Text   string
+	}{
+		Author: anonymize(comment.Username, d.cfg.Comments.Anonymize),
+		Text:   comment.Text,
+	}
+
+	var buf bytes.Buffer
+	if err := d.template.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func anonymize(username, mode string) string {
+	switch mode {
+	case AnonymizeHide:
+		return "Anonymous"
+	case AnonymizeMask:
+		runes := []rune(username)
+		if len(runes) <= 2 {
+			return strings.Repeat("*", len(runes))
+		}
+		return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+	default:
+		return "@" + username
+	}
+}
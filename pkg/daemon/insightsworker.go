@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
+)
+
+// InsightsWorker periodically samples engagement metrics of synced media on
+// instagram and of their VK counterparts and stores them as a time series.
+type InsightsWorker struct {
+	cfg        *config.Config
+	database   *sql.DB
+	metaClient *instagram.Client
+	vkClient   *vk.Client
+}
+
+func NewInsightsWorker(cfg *config.Config, database *sql.DB, metaClient *instagram.Client, vkClient *vk.Client) *InsightsWorker {
+	// set default fallback values
+	if cfg.Insights.Interval == 0 {
+		cfg.Insights.Interval = 3600
+	}
+	if cfg.Insights.MaxAgeDays == 0 {
+		cfg.Insights.MaxAgeDays = 30
+	}
+
+	return &InsightsWorker{
+		cfg:        cfg,
+		database:   database,
+		metaClient: metaClient,
+		vkClient:   vkClient,
+	}
+}
+
+func (m *InsightsWorker) Work(ctx context.Context) {
+	log.Printf("[worker:insights] InsightsWorker run")
+	for {
+		select {
+		case <-ctx.Done():
+			// Context was cancelled, stop the worker
+			return
+		default:
+			m.collect(ctx)
+			// Sleep for the configured duration before the next collection
+			select {
+			case <-time.After(time.Duration(m.cfg.Insights.Interval) * time.Second):
+			case <-ctx.Done():
+				// If context is cancelled, stop sleeping and return
+				return
+			}
+			break
+		}
+	}
+}
+
+func (d *InsightsWorker) collect(ctx context.Context) {
+	if vkPaused("worker:insights", d.vkClient) {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -d.cfg.Insights.MaxAgeDays)
+	records, err := db.SyncedRecords(string(source.KindMedia), since, d.database)
+	if err != nil {
+		log.Printf("[worker:insights:db] Failed to get synced records: %v", err)
+		return
+	}
+
+	// items not published to vk only get instagram insights
+	objs := make([]vk.Object, 0, len(records))
+	vkRecords := make([]db.Record, 0, len(records))
+	for _, r := range records {
+		now := time.Now()
+
+		metrics, err := d.metaClient.FetchInsights(ctx, r.ID, r.MediaType)
+		if err != nil {
+			log.Printf("[worker:insights] Failed to fetch instagram insights for %s: %v", r.ID, err)
+		} else if err := db.SaveMetrics(r.ID, db.PlatformInstagram, metrics, now, d.database); err != nil {
+			log.Printf("[worker:insights:db] Failed to save instagram insights for %s: %v", r.ID, err)
+		}
+
+		if r.VKID == 0 {
+			continue
+		}
+		objs = append(objs, vk.Object{Type: r.VKType, OwnerID: r.VKOwnerID, ID: r.VKID})
+		vkRecords = append(vkRecords, r)
+	}
+
+	// vk stats of all media are fetched in batches
+	stats, err := d.vkClient.StatsBatch(objs)
+	if err != nil {
+		log.Printf("[worker:insights] Failed to fetch vk stats: %v", err)
+		alertVKError("worker:insights", err)
+		return
+	}
+	now := time.Now()
+	for i, r := range vkRecords {
+		if stats[i] == nil {
+			log.Printf("[worker:insights] No vk stats for %s (vk %s %d_%d)", r.ID, r.VKType, r.VKOwnerID, r.VKID)
+			continue
+		}
+		if err := db.SaveMetrics(r.ID, db.PlatformVK, stats[i], now, d.database); err != nil {
+			log.Printf("[worker:insights:db] Failed to save vk stats for %s: %v", r.ID, err)
+		}
+	}
+
+	log.Printf("[worker:insights] Collected insights for %d media\n", len(records))
+}
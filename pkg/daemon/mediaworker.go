@@ -3,39 +3,78 @@ package daemon
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/s-yakubovskiy/inst2vk/pkg/config"
 	"github.com/s-yakubovskiy/inst2vk/pkg/db"
-	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
-	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/schedule"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
 	"github.com/s-yakubovskiy/inst2vk/pkg/storage"
-	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
 )
 
+// slots serializes picking and saving publish times, media workers of all
+// sources share the schedule and must not pick the same slot. It is only
+// held while a slot is reserved, not while the item is delivered.
+var slots sync.Mutex
+
 type MediaWorker struct {
-	cfg        *config.Config
-	database   *sql.DB
-	gcsClient  *storage.GCS
-	metaClient *instagram.Client
-	vkClient   *vk.Client
+	cfg       *config.Config
+	database  *sql.DB
+	gcsClient *storage.GCS
+	source    source.Source
+	// publishers are the destinations of the source's sync profile
+	publishers []publish.Publisher
+	// directory is the GCS directory media is staged in
+	directory string
+	// rules postpone posts to the posting windows, nil publishes right away
+	rules *schedule.Rules
 }
 
-func NewMediaWorker(cfg *config.Config, database *sql.DB, gcsClient *storage.GCS, metaClient *instagram.Client, vkClient *vk.Client) *MediaWorker {
+func NewMediaWorker(cfg *config.Config, database *sql.DB, gcsClient *storage.GCS, src source.Source, publishers []publish.Publisher) (*MediaWorker, error) {
+	// set default fallback values
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	// instagram posts live in "posts", other sources get their own directory
+	directory := "posts"
+	if src.Kind() != source.KindMedia {
+		directory = string(src.Kind())
+	}
+
+	var rules *schedule.Rules
+	if cfg.VK.Schedule.Enabled {
+		// videos are postponed as wall posts, without one they'd go out
+		// right away while their slot is recorded
+		if cfg.VK.Video.Wallpost != nil && !*cfg.VK.Video.Wallpost {
+			return nil, errors.New("vk.schedule needs vk.video.wallpost")
+		}
+		var err error
+		rules, err = schedule.NewRules(cfg.VK.Schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &MediaWorker{
 		cfg:        cfg,
 		database:   database,
 		gcsClient:  gcsClient,
-		metaClient: metaClient,
-		vkClient:   vkClient,
-	}
+		source:     src,
+		publishers: publishers,
+		directory:  directory,
+		rules:      rules,
+	}, nil
 }
 
 func (m *MediaWorker) Work(ctx context.Context) {
-	log.Printf("[worker:media] MediaWorker run")
+	log.Printf("[worker:media] MediaWorker run (source: %s)", m.source.Kind())
 	for {
 		select {
 		case <-ctx.Done():
@@ -56,85 +95,306 @@ func (m *MediaWorker) Work(ctx context.Context) {
 }
 
 func (d *MediaWorker) processMedia(ctx context.Context) {
-	// Fetch media ids
-	ids, err := d.metaClient.FetchMediaIds("media")
+	// Fetch media page
+	page, err := d.source.List(ctx, "")
 	if err != nil {
-		log.Printf("[worker:media]Failed to fetch media ids: %v", err)
+		log.Printf("[worker:media]Failed to fetch media page: %v", err)
 		return
 	}
 
-	// For each id, fetch the media details
-	for _, id := range ids {
-		d.syncMedia(ctx, id)
+	d.syncItems(ctx, "worker:media", page.Items)
+}
+
+// syncItems publishes the items strictly in creation order. A failed item
+// holds back everything after it until the next cycle, or until it used up
+// its attempts.
+func (d *MediaWorker) syncItems(ctx context.Context, worker string, items []*source.Item) {
+	table := string(d.source.Kind())
+	for _, media := range items {
+		err := d.syncMedia(ctx, media)
+		if err != nil && holdBack(worker, media.ID, table, err, d.cfg.MaxAttempts, d.database) {
+			return
+		}
 	}
 }
 
-func (d *MediaWorker) syncMedia(ctx context.Context, id string) {
-	synced, err := db.CheckAndInsert(id, "media", d.database)
+// syncMedia publishes the item unless it is synced or failed already.
+func (d *MediaWorker) syncMedia(ctx context.Context, media *source.Item) error {
+	id := media.ID
+	table := string(d.source.Kind())
+	synced, err := db.CheckAndInsert(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:media] Failed to check and insert media id: %v", err)
-		return
+		return fmt.Errorf("check and insert media id: %w", err)
 	}
 
 	if synced {
 		log.Printf("[worker:media:db] %+v %s\n", id, "is already synced.")
-		return
+		return nil
 	}
 
-	media, err := d.metaClient.FetchMediaDetail(id)
-	// fmt.Printf("[insta] %+v | id %+v\n", media.MediaURL, id)
+	failed, err := db.IsFailed(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:media] Failed to fetch media details: %v", err)
-		return
+		return fmt.Errorf("check failed state: %w", err)
+	}
+	if failed {
+		return nil
 	}
 
-	// download current media to mediaReader with retry 3
-	mediaReader, err := downloader.DownloadFile(media.MediaURL)
+	err = db.SaveItem(media, table, d.database)
+	if err != nil {
+		return fmt.Errorf("save media details: %w", err)
+	}
 
+	publishAt, err := d.reserveSlot(id, table)
 	if err != nil {
-		fmt.Println("[worker:media] Error downloading file:", err)
-		return
+		return fmt.Errorf("schedule post: %w", err)
 	}
 
-	// Upload the media to GCS
-	err = d.gcsClient.Upload(ctx, "posts", id, mediaReader)
+	// Text only items have nothing to stage
+	staged, err := d.stage(ctx, media)
+	if err != nil {
+		return fmt.Errorf("stage media: %w", err)
+	}
 
+	post := &publish.Post{
+		Item:      media,
+		Caption:   media.Caption,
+		Media:     staged,
+		PublishAt: publishAt,
+	}
+	if err := d.deliver(ctx, id, post); err != nil {
+		d.releaseSlot(id, table)
+		return err
+	}
+	if !publishAt.IsZero() {
+		log.Printf("[worker:media] %s is scheduled for %s\n", id, publishAt.Format(time.RFC3339))
+	}
+
+	// If media is successfully uploaded, update the media record as synced in the database
+	err = db.MarkAsSynced(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:media] Failed to upload to GCS: %v", err)
-		return
+		return fmt.Errorf("update media as synced: %w", err)
+	}
+
+	log.Printf("[worker:media:inst2vk] Successfully transferred & synced media id: %s\n", id)
+	return nil
+}
+
+// reserveSlot picks the publish time of the item and saves it right away, so
+// media workers of other sources see the slot as taken while the item is
+// staged and delivered. Zero publishes right away, that is saved as now.
+// A slot kept for an upload VK is still processing is reused, a single
+// video is already postponed to it.
+func (d *MediaWorker) reserveSlot(id, table string) (time.Time, error) {
+	if d.rules == nil {
+		return time.Time{}, nil
 	}
 
-	// Upload to VK
-	urlDL := d.gcsClient.ReturnPublicURL(ctx, "posts", id)
+	slots.Lock()
+	defer slots.Unlock()
 
-	resp, err := http.Get(urlDL)
+	kept, err := db.PublishAt(id, table, d.database)
 	if err != nil {
-		log.Println(err)
-		return
+		return time.Time{}, fmt.Errorf("read publish time: %w", err)
+	}
+	if kept.After(time.Now()) {
+		return kept, nil
+	}
+	if !kept.IsZero() {
+		// the slot has passed while VK was processing, publish right away
+		return time.Time{}, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println(resp.Status)
-		return
+	publishAt, err := d.publishAt()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	reserved := publishAt
+	if reserved.IsZero() {
+		reserved = time.Now()
+	}
+	if err := db.SavePublishAt(id, table, reserved, d.database); err != nil {
+		return time.Time{}, fmt.Errorf("save publish time: %w", err)
 	}
+	return publishAt, nil
+}
 
-	if media.MediaType == "IMAGE" {
-		err = d.vkClient.UploadWallPhoto(media.Caption, media.Caption, resp.Body)
-	} else {
-		err = d.vkClient.UploadVideo(media.Caption, media.Caption, resp.Body)
+// releaseSlot frees the slot of an item that failed to deliver, the next
+// attempt picks a new one. The slot of an upload a destination is still
+// processing is kept for the next attempt.
+func (d *MediaWorker) releaseSlot(id, table string) {
+	if d.rules == nil {
+		return
 	}
+	processing, err := processingDeliveries(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:media] Failed to vk upload: %+v\n", err)
+		log.Printf("[worker:media:db] Failed to read deliveries of %s: %v\n", id, err)
+		return
+	}
+	if len(processing) > 0 {
 		return
 	}
+	if err := db.ClearPublishAt(id, table, d.database); err != nil {
+		log.Printf("[worker:media:db] Failed to release the slot of %s: %v\n", id, err)
+	}
+}
 
-	// If media is successfully uploaded, update the media record as synced in the database
-	err = db.MarkAsSynced(id, "media", d.database)
+// publishAt picks the publish time of the next post according to the
+// schedule rules, zero publishes right away.
+func (d *MediaWorker) publishAt() (time.Time, error) {
+	if d.rules == nil {
+		return time.Time{}, nil
+	}
+
+	// the daily limit only looks at the current day, the gap at the last post
+	now := time.Now()
+	taken, err := db.PublishTimes(now.Add(-48*time.Hour), d.database)
 	if err != nil {
-		log.Printf("[worker:media:db] Failed to update media as synced: %v", err)
-		return
+		return time.Time{}, err
 	}
 
-	log.Printf("[worker:media:inst2vk] Successfully transferred & synced media id: %s\n", id)
+	return d.rules.Next(now, taken), nil
+}
+
+// stage uploads the media of the item, or every part of a carousel, to GCS
+// where publishers download it from.
+func (d *MediaWorker) stage(ctx context.Context, media *source.Item) ([]publish.Media, error) {
+	if media.MediaType == source.MediaTypeText {
+		return nil, nil
+	}
+
+	parts := []*source.Item{media}
+	if len(media.Children) > 0 {
+		parts = media.Children
+	}
+
+	staged := make([]publish.Media, 0, len(parts))
+	for _, part := range parts {
+		// download current media to mediaReader with retry 3
+		mediaReader, err := d.source.Download(ctx, part)
+		if err != nil {
+			return nil, fmt.Errorf("download: %w", err)
+		}
+
+		contentType, content, err := source.SniffContentType(mediaReader, part)
+		if err != nil {
+			mediaReader.Close()
+			return nil, fmt.Errorf("download: %w", err)
+		}
+
+		// Upload the media to GCS
+		err = d.gcsClient.Upload(ctx, d.directory, part.ID, content)
+		mediaReader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("upload to GCS: %w", err)
+		}
+
+		staged = append(staged, publish.Media{
+			Type:         part.MediaType,
+			URL:          d.gcsClient.ReturnPublicURL(ctx, d.directory, part.ID),
+			ContentType:  contentType,
+			ThumbnailURL: part.ThumbnailURL,
+		})
+	}
+
+	return staged, nil
+}
+
+// deliver publishes the post to every destination of the profile it is not
+// published to yet. A failed or paused destination holds the item back so the
+// next cycle retries it.
+func (d *MediaWorker) deliver(ctx context.Context, id string, post *publish.Post) error {
+	table := string(d.source.Kind())
+	done, err := settled(id, table, d.database)
+	if err != nil {
+		return fmt.Errorf("get deliveries: %w", err)
+	}
+	processing, err := processingDeliveries(id, table, d.database)
+	if err != nil {
+		return fmt.Errorf("get deliveries: %w", err)
+	}
+
+	// paused and processing destinations hold the item back without failing
+	// it
+	var errs []error
+	var paused, pending error
+	// destinations is every destination the item has to be settled at
+	var destinations []string
+	for _, p := range d.publishers {
+		if done[p.Name()] {
+			destinations = append(destinations, p.Name())
+			continue
+		}
+
+		caps := p.Capabilities()
+		if !caps.Supports(post.Media) {
+			log.Printf("[worker:media] %s does not support %s, skipping %s\n", p.Name(), post.Item.MediaType, id)
+			continue
+		}
+		destinations = append(destinations, p.Name())
+		if err := publish.Paused(p); err != nil {
+			paused = fmt.Errorf("%s: %w: %v", p.Name(), errPaused, err)
+			continue
+		}
+
+		out := *post
+		out.Caption = publish.Truncate(post.Caption, caps.CaptionLimit(post.Media))
+		if caps.MaxAttachments > 0 && len(out.Media) > caps.MaxAttachments {
+			out.Media = out.Media[:caps.MaxAttachments]
+		}
+		if !caps.Schedule {
+			out.PublishAt = time.Time{}
+		}
+		out.Processing = publish.Ref(processing[p.Name()].Processing)
+		out.ProcessingSince = processing[p.Name()].ProcessingSince
+
+		published, err := p.Post(ctx, &out)
+		var processingErr *publish.ProcessingError
+		if errors.As(err, &processingErr) {
+			if err := saveProcessing("worker:media", id, table, p.Name(), processingErr, d.database); err != nil {
+				errs = append(errs, fmt.Errorf("save %s processing: %w", p.Name(), err))
+			}
+			pending = fmt.Errorf("%s: %w: %v", p.Name(), errProcessing, err)
+			continue
+		}
+		if errors.Is(err, publish.ErrRejected) {
+			if err := saveRejected("worker:media", id, table, p.Name(), err, d.database); err != nil {
+				errs = append(errs, fmt.Errorf("save %s rejection: %w", p.Name(), err))
+			}
+			continue
+		}
+		if err != nil {
+			alertVKError("worker:media", err)
+			if err := db.SaveDeliveryError(id, table, p.Name(), err.Error(), d.database); err != nil {
+				log.Printf("[worker:media:db] Failed to save delivery error: %v", err)
+			}
+			errs = append(errs, fmt.Errorf("publish to %s: %w", p.Name(), err))
+			continue
+		}
+
+		err = saveDelivery(id, table, p.Name(), published, d.database)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("save %s delivery: %w", p.Name(), err))
+		}
+	}
+
+	// the recorded states decide whether the item is done
+	left, err := unsettled(id, table, destinations, d.database)
+	if err != nil {
+		return fmt.Errorf("get deliveries: %w", err)
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if paused != nil {
+		return paused
+	}
+	if pending != nil {
+		return pending
+	}
+	return fmt.Errorf("not delivered to %s", strings.Join(left, ", "))
 }
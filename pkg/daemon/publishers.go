@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
+)
+
+// publisherFor returns the publisher of the destination or nil.
+func publisherFor(publishers []publish.Publisher, name string) publish.Publisher {
+	for _, p := range publishers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// publisherPaused reports whether the publisher is paused, logging why.
+func publisherPaused(worker string, p publish.Publisher) bool {
+	err := publish.Paused(p)
+	if err != nil {
+		log.Printf("[%s] Skipping %s: %v", worker, p.Name(), err)
+	}
+	return err != nil
+}
+
+// settled returns the destinations the item needs nothing more from, it is
+// published there or the destination rejected it for good.
+func settled(id, table string, database *sql.DB) (map[string]bool, error) {
+	deliveries, err := db.Deliveries(id, table, database)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(deliveries))
+	for _, d := range deliveries {
+		if d.Ref != "" || d.Rejected {
+			done[d.Destination] = true
+		}
+	}
+	return done, nil
+}
+
+// processingDeliveries returns the deliveries of the item whose uploads the
+// destinations are still processing, by destination.
+func processingDeliveries(id, table string, database *sql.DB) (map[string]db.Delivery, error) {
+	deliveries, err := db.Deliveries(id, table, database)
+	if err != nil {
+		return nil, err
+	}
+
+	processing := make(map[string]db.Delivery)
+	for _, d := range deliveries {
+		if d.Processing != "" {
+			processing[d.Destination] = d
+		}
+	}
+	return processing, nil
+}
+
+// unsettled returns the destinations whose recorded delivery of the item is
+// not settled yet. The item is done once none is left.
+func unsettled(id, table string, destinations []string, database *sql.DB) ([]string, error) {
+	done, err := settled(id, table, database)
+	if err != nil {
+		return nil, err
+	}
+
+	var left []string
+	for _, name := range destinations {
+		if !done[name] {
+			left = append(left, name)
+		}
+	}
+	return left, nil
+}
+
+// saveProcessing records the upload the destination is still processing, the
+// next attempt checks it instead of uploading again.
+func saveProcessing(worker, id, table, destination string, err *publish.ProcessingError, database *sql.DB) error {
+	log.Printf("[%s] %s is still processing %s: %v\n", worker, destination, id, err)
+	return db.SaveDeliveryProcessing(id, table, destination, string(err.Ref), err.Error(), database)
+}
+
+// saveRejected records a permanent rejection of the item by the destination,
+// the item is not retried for it.
+func saveRejected(worker, id, table, destination string, err error, database *sql.DB) error {
+	log.Printf("[alert:%s] %s rejected %s: %v\n", worker, destination, id, err)
+	return db.SaveDeliveryRejected(id, table, destination, err.Error(), database)
+}
+
+// saveDelivery records the published object. VK objects are mirrored into the
+// vk columns the comments, insights and reconcile workers read.
+func saveDelivery(id, table, destination string, published *publish.Published, database *sql.DB) error {
+	err := db.SaveDelivery(id, table, destination, string(published.Ref), published.ExpiresAt, database)
+	if err != nil || destination != vk.Destination {
+		return err
+	}
+
+	obj, err := vk.ParseRef(published.Ref)
+	if err != nil {
+		return err
+	}
+	if err := db.SaveVKObject(id, table, obj.Type, obj.OwnerID, obj.ID, database); err != nil {
+		return err
+	}
+	if published.ExpiresAt.IsZero() {
+		return nil
+	}
+	return db.SaveVKExpiry(id, table, published.ExpiresAt, database)
+}
+
+// deleteDeliveries deletes every published copy of the item that has not
+// expired yet.
+func deleteDeliveries(ctx context.Context, worker, id, table string, publishers []publish.Publisher, database *sql.DB) error {
+	deliveries, err := db.Deliveries(id, table, database)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if d.Ref == "" || (!d.ExpiresAt.IsZero() && time.Now().After(d.ExpiresAt)) {
+			continue
+		}
+
+		p := publisherFor(publishers, d.Destination)
+		if p == nil || !p.Capabilities().Delete {
+			return fmt.Errorf("%s %s: %w", d.Destination, d.Ref, publish.ErrNotSupported)
+		}
+		if err := p.Delete(ctx, publish.Ref(d.Ref)); err != nil {
+			alertVKError(worker, err)
+			return fmt.Errorf("%s %s: %w", d.Destination, d.Ref, err)
+		}
+	}
+	return nil
+}
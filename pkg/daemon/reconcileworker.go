@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
+)
+
+// Policies applied to published copies of media deleted on instagram.
+const (
+	PolicyDelete  = "delete"
+	PolicyArchive = "archive"
+	PolicyFlag    = "flag"
+)
+
+// ReconcileWorker detects synced media deleted or archived on instagram and
+// deletes, archives or flags their published copies once they stayed missing
+// for the grace period. Only VK copies can be archived, the others are kept
+// and flagged.
+type ReconcileWorker struct {
+	cfg        *config.Config
+	database   *sql.DB
+	metaClient *instagram.Client
+	vkClient   *vk.Client
+	publishers []publish.Publisher
+}
+
+func NewReconcileWorker(cfg *config.Config, database *sql.DB, metaClient *instagram.Client, vkClient *vk.Client, publishers []publish.Publisher) (*ReconcileWorker, error) {
+	// set default fallback values
+	if cfg.Reconcile.Interval == 0 {
+		cfg.Reconcile.Interval = 3600
+	}
+	if cfg.Reconcile.MaxAgeDays == 0 {
+		cfg.Reconcile.MaxAgeDays = 30
+	}
+	if cfg.Reconcile.GracePeriod == 0 {
+		cfg.Reconcile.GracePeriod = 86400
+	}
+
+	switch cfg.Reconcile.Policy {
+	case "":
+		cfg.Reconcile.Policy = PolicyFlag
+	case PolicyDelete, PolicyArchive, PolicyFlag:
+	default:
+		return nil, fmt.Errorf("unknown reconcile policy: %s", cfg.Reconcile.Policy)
+	}
+
+	return &ReconcileWorker{
+		cfg:        cfg,
+		database:   database,
+		metaClient: metaClient,
+		vkClient:   vkClient,
+		publishers: publishers,
+	}, nil
+}
+
+func (m *ReconcileWorker) Work(ctx context.Context) {
+	log.Printf("[worker:reconcile] ReconcileWorker run (policy: %s, dry run: %t)", m.cfg.Reconcile.Policy, m.cfg.Reconcile.DryRun)
+	for {
+		select {
+		case <-ctx.Done():
+			// Context was cancelled, stop the worker
+			return
+		default:
+			m.reconcile(ctx)
+			// Sleep for the configured duration before the next pass
+			select {
+			case <-time.After(time.Duration(m.cfg.Reconcile.Interval) * time.Second):
+			case <-ctx.Done():
+				// If context is cancelled, stop sleeping and return
+				return
+			}
+			break
+		}
+	}
+}
+
+func (d *ReconcileWorker) reconcile(ctx context.Context) {
+	table := string(source.KindMedia)
+	since := time.Now().AddDate(0, 0, -d.cfg.Reconcile.MaxAgeDays)
+	records, err := db.SyncedRecords(table, since, d.database)
+	if err != nil {
+		log.Printf("[worker:reconcile:db] Failed to get synced records: %v", err)
+		return
+	}
+
+	for _, r := range records {
+		_, err := d.metaClient.FetchMediaDetail(ctx, r.ID)
+		if err != nil && !instagram.IsNotFound(err) {
+			// anything but a definite "not found" may be a glitch, don't act on it
+			log.Printf("[worker:reconcile] Failed to fetch media details for %s: %v", r.ID, err)
+			continue
+		}
+
+		if err == nil {
+			if !r.MissingSince.IsZero() {
+				log.Printf("[worker:reconcile] %s is back on instagram\n", r.ID)
+				if err := db.SetMissing(r.ID, table, time.Time{}, d.database); err != nil {
+					log.Printf("[worker:reconcile:db] Failed to clear missing mark for %s: %v", r.ID, err)
+				}
+			}
+			continue
+		}
+
+		if r.MissingSince.IsZero() {
+			log.Printf("[worker:reconcile] %s is missing on instagram\n", r.ID)
+			if err := db.SetMissing(r.ID, table, time.Now(), d.database); err != nil {
+				log.Printf("[worker:reconcile:db] Failed to mark %s as missing: %v", r.ID, err)
+			}
+			continue
+		}
+
+		if time.Since(r.MissingSince) < time.Duration(d.cfg.Reconcile.GracePeriod)*time.Second {
+			continue
+		}
+
+		d.apply(ctx, r, table)
+	}
+}
+
+// apply handles the published copies of the missing media according to the
+// policy.
+func (d *ReconcileWorker) apply(ctx context.Context, r db.Record, table string) {
+	policy := d.cfg.Reconcile.Policy
+
+	if d.cfg.Reconcile.DryRun {
+		log.Printf("[worker:reconcile] dry run: would %s published copies of media %s\n", policy, r.ID)
+		return
+	}
+
+	state := db.VKStateFlagged
+	var err error
+	switch policy {
+	case PolicyDelete:
+		err = deleteDeliveries(ctx, "worker:reconcile", r.ID, table, d.publishers, d.database)
+		state = db.VKStateDeleted
+	case PolicyArchive:
+		if r.VKID == 0 {
+			break
+		}
+		if vkPaused("worker:reconcile", d.vkClient) {
+			return
+		}
+		obj := vk.Object{Type: r.VKType, OwnerID: r.VKOwnerID, ID: r.VKID}
+		err = d.vkClient.Archive(obj)
+		state = db.VKStateArchived
+		if errors.Is(err, vk.ErrNotSupported) {
+			log.Printf("[worker:reconcile] Can't archive vk %s, flagging media %s instead\n", obj.Type, r.ID)
+			err = nil
+			state = db.VKStateFlagged
+		}
+		if err != nil {
+			alertVKError("worker:reconcile", err)
+		}
+	}
+	if err != nil {
+		log.Printf("[worker:reconcile] Failed to %s published copies of media %s: %v\n", policy, r.ID, err)
+		return
+	}
+
+	err = db.SetVKState(r.ID, table, state, d.database)
+	if err != nil {
+		log.Printf("[worker:reconcile:db] Failed to save state of %s: %v", r.ID, err)
+		return
+	}
+
+	log.Printf("[worker:reconcile:inst2vk] Media %s is gone from instagram, published copies %s\n", r.ID, state)
+}
@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+)
+
+// errPaused is returned for items held back by a paused publisher. Waiting
+// for a publisher does not count as a failed attempt of the item.
+var errPaused = errors.New("publisher is paused")
+
+// errProcessing is returned for items a destination is still processing.
+// Like a pause it holds the item back without counting as a failed attempt,
+// the next cycle checks the upload again.
+var errProcessing = errors.New("destination is still processing the item")
+
+// holdBack records the failed attempt to sync the item and reports whether
+// it still holds back the items after it. Items are published in creation
+// order, so a failed item is retried first on the next cycle until it used
+// up its attempts, then it is marked failed and the items after it go on.
+func holdBack(worker, id, table string, err error, maxAttempts int, database *sql.DB) bool {
+	if errors.Is(err, errPaused) {
+		log.Printf("[%s] %s waits for a paused publisher: %v\n", worker, id, err)
+		return true
+	}
+	if errors.Is(err, errProcessing) {
+		log.Printf("[%s] %s is still processing, checking again on the next cycle: %v\n", worker, id, err)
+		return true
+	}
+
+	failed, dbErr := db.SaveAttempt(id, table, err.Error(), maxAttempts, database)
+	if dbErr != nil {
+		log.Printf("[%s:db] Failed to save attempt of %s: %v", worker, id, dbErr)
+		return true
+	}
+	if failed {
+		log.Printf("[alert:%s] Giving up on %s after %d attempts: %v\n", worker, id, maxAttempts, err)
+		return false
+	}
+
+	log.Printf("[%s] Failed to sync %s, retrying on the next cycle: %v\n", worker, id, err)
+	return true
+}
@@ -1,41 +1,75 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/s-yakubovskiy/inst2vk/pkg/config"
 	"github.com/s-yakubovskiy/inst2vk/pkg/db"
-	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
-	"github.com/s-yakubovskiy/inst2vk/pkg/instagram"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
 	"github.com/s-yakubovskiy/inst2vk/pkg/storage"
-	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
 )
 
+// storyLifetime is how long instagram and VK stories stay visible.
+const storyLifetime = 24 * time.Hour
+
 type StoryWorker struct {
-	cfg        *config.Config
-	database   *sql.DB
-	gcsClient  *storage.GCS
-	metaClient *instagram.Client
-	vkClient   *vk.Client
+	cfg       *config.Config
+	database  *sql.DB
+	gcsClient *storage.GCS
+	source    source.Source
+	// publishers are the destinations of the stories profile that take
+	// stories
+	publishers []publish.Publisher
+	linkURL    *template.Template
 }
 
-func NewStoryWorker(cfg *config.Config, database *sql.DB, gcsClient *storage.GCS, metaClient *instagram.Client, vkClient *vk.Client) *StoryWorker {
+func NewStoryWorker(cfg *config.Config, database *sql.DB, gcsClient *storage.GCS, src source.Source, publishers []publish.Publisher) (*StoryWorker, error) {
+	// set default fallback values
+	if cfg.Stories.Interval == 0 {
+		cfg.Stories.Interval = cfg.SleepInterval
+	}
+	if cfg.Stories.LinkText == "" {
+		cfg.Stories.LinkText = "more"
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	linkURL, err := template.New("link_url").Parse(cfg.Stories.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var storyPublishers []publish.Publisher
+	for _, p := range publishers {
+		if p.Capabilities().Stories {
+			storyPublishers = append(storyPublishers, p)
+		} else {
+			log.Printf("[worker:story] %s does not support stories, skipping it", p.Name())
+		}
+	}
+
 	return &StoryWorker{
 		cfg:        cfg,
 		database:   database,
 		gcsClient:  gcsClient,
-		metaClient: metaClient,
-		vkClient:   vkClient,
-	}
+		source:     src,
+		publishers: storyPublishers,
+		linkURL:    linkURL,
+	}, nil
 }
 
 func (m *StoryWorker) Work(ctx context.Context) {
-	log.Printf("[worker:story] StoryWorker run")
+	log.Printf("[worker:story] StoryWorker run (interval: %ds)", m.cfg.Stories.Interval)
 	for {
 		select {
 		case <-ctx.Done():
@@ -45,7 +79,7 @@ func (m *StoryWorker) Work(ctx context.Context) {
 			m.processMedia(ctx)
 			// Sleep for the configured duration before checking for new media
 			select {
-			case <-time.After(time.Duration(m.cfg.SleepInterval) * time.Second):
+			case <-time.After(time.Duration(m.cfg.Stories.Interval) * time.Second):
 			case <-ctx.Done():
 				// If context is cancelled, stop sleeping and return
 				return
@@ -56,85 +90,227 @@ func (m *StoryWorker) Work(ctx context.Context) {
 }
 
 func (d *StoryWorker) processMedia(ctx context.Context) {
-	// Fetch media ids
-	ids, err := d.metaClient.FetchMediaIds("stories")
+	// Fetch media page
+	page, err := d.source.List(ctx, "")
 	if err != nil {
-		log.Printf("[worker:story]: Failed to fetch media ids: %v", err)
+		log.Printf("[worker:story]: Failed to fetch media page: %v", err)
 		return
 	}
 
-	// For each id, fetch the media details
-	for _, id := range ids {
-		d.syncStory(ctx, id)
+	// Items are published strictly in creation order, a failed item holds
+	// back everything after it until the next cycle or until it used up its
+	// attempts. Items come oldest first, so stories closest to expiry go first
+	table := string(d.source.Kind())
+	for _, media := range page.Items {
+		if !media.Timestamp.IsZero() && time.Since(media.Timestamp) >= storyLifetime {
+			log.Printf("[worker:story] %s has expired, skipping\n", media.ID)
+			continue
+		}
+		err := d.syncStory(ctx, media)
+		if err != nil && holdBack("worker:story", media.ID, table, err, d.cfg.MaxAttempts, d.database) {
+			return
+		}
+	}
+
+	// A partial page can't tell removed stories from unlisted ones, and an
+	// empty one is more likely a glitch than every story being removed
+	if d.cfg.Stories.DeleteRemoved && page.Next == "" && len(page.Items) > 0 {
+		d.deleteRemoved(ctx, page)
 	}
 }
 
-func (d *StoryWorker) syncStory(ctx context.Context, id string) {
-	synced, err := db.CheckAndInsert(id, "stories", d.database)
+// deleteRemoved deletes published stories whose instagram story was removed
+// before it expired.
+func (d *StoryWorker) deleteRemoved(ctx context.Context, page *source.Page) {
+	table := string(d.source.Kind())
+	records, err := db.SyncedRecords(table, time.Now().Add(-storyLifetime), d.database)
 	if err != nil {
-		log.Printf("[worker:story]: Failed to check and insert story id: %v", err)
+		log.Printf("[worker:story:db] Failed to get synced stories: %v", err)
 		return
 	}
 
+	listed := make(map[string]bool)
+	for _, media := range page.Items {
+		listed[media.ID] = true
+	}
+
+	for _, r := range records {
+		if listed[r.ID] {
+			continue
+		}
+		// stories about to expire may just have dropped out of the listing
+		if time.Until(r.Timestamp.Add(storyLifetime)) < time.Minute {
+			continue
+		}
+		if !r.VKExpiresAt.IsZero() && time.Now().After(r.VKExpiresAt) {
+			continue
+		}
+
+		err := deleteDeliveries(ctx, "worker:story", r.ID, table, d.publishers, d.database)
+		if err != nil {
+			log.Printf("[worker:story] Failed to delete stories of %s: %v\n", r.ID, err)
+			continue
+		}
+		if err := db.SetVKState(r.ID, table, db.VKStateDeleted, d.database); err != nil {
+			log.Printf("[worker:story:db] Failed to save vk state of %s: %v", r.ID, err)
+			continue
+		}
+
+		log.Printf("[worker:story:inst2vk] Story %s was removed from instagram, deleted published stories\n", r.ID)
+	}
+}
+
+// syncStory publishes the story unless it is synced or failed already.
+func (d *StoryWorker) syncStory(ctx context.Context, media *source.Item) error {
+	id := media.ID
+	table := string(d.source.Kind())
+	synced, err := db.CheckAndInsert(id, table, d.database)
+	if err != nil {
+		return fmt.Errorf("check and insert story id: %w", err)
+	}
+
 	if synced {
 		log.Printf("[worker:story:db] %+v %s\n", id, "is already synced.")
-		return
+		return nil
 	}
 
-	media, err := d.metaClient.FetchMediaDetail(id)
-	// fmt.Printf("[insta] %+v | id %+v\n", media.MediaURL, id)
+	failed, err := db.IsFailed(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:story]: Failed to fetch media details: %v", err)
-		return
+		return fmt.Errorf("check failed state: %w", err)
+	}
+	if failed {
+		return nil
+	}
+
+	err = db.SaveItem(media, table, d.database)
+	if err != nil {
+		return fmt.Errorf("save media details: %w", err)
 	}
 
 	// download current media to mediaReader with retry 3
-	mediaReader, err := downloader.DownloadFile(media.MediaURL)
+	mediaReader, err := d.source.Download(ctx, media)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	defer mediaReader.Close()
 
+	contentType, content, err := source.SniffContentType(mediaReader, media)
 	if err != nil {
-		fmt.Println("[worker:story]: Error downloading file:", err)
-		return
+		return fmt.Errorf("download: %w", err)
 	}
 
 	// Upload the media to GCS
-	err = d.gcsClient.Upload(ctx, "stories", id, mediaReader)
-
+	err = d.gcsClient.Upload(ctx, "stories", id, content)
 	if err != nil {
-		log.Printf("[worker:story]: Failed to upload to GCS: %v", err)
-		return
+		return fmt.Errorf("upload to GCS: %w", err)
 	}
 
-	// Upload to VK
-	urlDL := d.gcsClient.ReturnPublicURL(ctx, "stories", id)
-
-	resp, err := http.Get(urlDL)
+	linkURL, err := d.renderLink(media)
 	if err != nil {
-		log.Println(err)
-		return
+		return fmt.Errorf("render story link: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println(resp.Status)
-		return
+	profile := d.cfg.StoryProfiles[string(d.source.Kind())]
+	story := &publish.Story{
+		Item: media,
+		Media: publish.Media{
+			Type:         media.MediaType,
+			URL:          d.gcsClient.ReturnPublicURL(ctx, "stories", id),
+			ContentType:  contentType,
+			ThumbnailURL: media.ThumbnailURL,
+		},
+		LinkText:     d.cfg.Stories.LinkText,
+		LinkURL:      linkURL,
+		ReplyToStory: profile.ReplyToStory,
+		UserIDs:      profile.UserIDs,
+	}
+	if err := d.deliver(ctx, id, story); err != nil {
+		return err
 	}
 
-	if media.MediaType == "IMAGE" {
-		err = d.vkClient.UploadStoryPhoto(resp.Body)
-	} else {
-		err = d.vkClient.UploadStoryVideo(resp.Body)
+	// If media is successfully uploaded, update the media record as synced in the database
+	err = db.MarkAsSynced(id, table, d.database)
+	if err != nil {
+		return fmt.Errorf("update story as synced: %w", err)
 	}
+
+	log.Printf("[worker:story:inst2vk] Successfully transferred story id: %s\n", id)
+	return nil
+}
+
+// deliver publishes the story to every destination it is not published to
+// yet. A failed or paused destination holds the item back.
+func (d *StoryWorker) deliver(ctx context.Context, id string, story *publish.Story) error {
+	table := string(d.source.Kind())
+	done, err := settled(id, table, d.database)
 	if err != nil {
-		log.Printf("[worker:story] Failed to vk upload: %+v\n", err)
-		return
+		return fmt.Errorf("get deliveries: %w", err)
 	}
 
-	// If media is successfully uploaded, update the media record as synced in the database
-	err = db.MarkAsSynced(id, "stories", d.database)
+	// paused destinations hold the item back without failing it
+	var errs []error
+	var paused error
+	// destinations is every destination the item has to be settled at
+	var destinations []string
+	for _, p := range d.publishers {
+		if done[p.Name()] {
+			destinations = append(destinations, p.Name())
+			continue
+		}
+		if !p.Capabilities().Supports([]publish.Media{story.Media}) {
+			log.Printf("[worker:story] %s does not support %s, skipping %s\n", p.Name(), story.Media.Type, id)
+			continue
+		}
+		destinations = append(destinations, p.Name())
+		if err := publish.Paused(p); err != nil {
+			paused = fmt.Errorf("%s: %w: %v", p.Name(), errPaused, err)
+			continue
+		}
+
+		published, err := p.Story(ctx, story)
+		if errors.Is(err, publish.ErrRejected) {
+			if err := saveRejected("worker:story", id, table, p.Name(), err, d.database); err != nil {
+				errs = append(errs, fmt.Errorf("save %s rejection: %w", p.Name(), err))
+			}
+			continue
+		}
+		if err != nil {
+			alertVKError("worker:story", err)
+			if err := db.SaveDeliveryError(id, table, p.Name(), err.Error(), d.database); err != nil {
+				log.Printf("[worker:story:db] Failed to save delivery error: %v", err)
+			}
+			errs = append(errs, fmt.Errorf("publish to %s: %w", p.Name(), err))
+			continue
+		}
+
+		err = saveDelivery(id, table, p.Name(), published, d.database)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("save %s delivery: %w", p.Name(), err))
+		}
+	}
+
+	// the recorded states decide whether the item is done
+	left, err := unsettled(id, table, destinations, d.database)
 	if err != nil {
-		log.Printf("[worker:story:db]Failed to update story as synced: %v", err)
-		return
+		return fmt.Errorf("get deliveries: %w", err)
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
+	if paused != nil {
+		return paused
+	}
+	return fmt.Errorf("not delivered to %s", strings.Join(left, ", "))
+}
 
-	log.Printf("[worker:story:inst2vk] Successfully transferred story id: %s\n", id)
+// renderLink renders the link button url for the story.
+func (d *StoryWorker) renderLink(media *source.Item) (string, error) {
+	var buf bytes.Buffer
+	if err := d.linkURL.Execute(&buf, media); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
 }
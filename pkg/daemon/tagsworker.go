@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+	"github.com/s-yakubovskiy/inst2vk/pkg/storage"
+)
+
+const defaultTagsCaption = "{{.Caption}}\n\n📷 @{{.Username}}\n{{.Permalink}}"
+
+// TagsWorker reposts media our account is tagged in. Only authors from the
+// allow-list are reposted and captions credit the original author.
+type TagsWorker struct {
+	*MediaWorker
+	allowed map[string]bool
+	caption *template.Template
+}
+
+func NewTagsWorker(cfg *config.Config, database *sql.DB, gcsClient *storage.GCS, src source.Source, publishers []publish.Publisher) (*TagsWorker, error) {
+	text := cfg.Instagram.Tags.CaptionTemplate
+	if text == "" {
+		text = defaultTagsCaption
+	}
+	caption, err := template.New("caption").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool)
+	for _, username := range cfg.Instagram.Tags.AllowedUsernames {
+		allowed[strings.ToLower(strings.TrimPrefix(username, "@"))] = true
+	}
+
+	media, err := NewMediaWorker(cfg, database, gcsClient, src, publishers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TagsWorker{
+		MediaWorker: media,
+		allowed:     allowed,
+		caption:     caption,
+	}, nil
+}
+
+func (m *TagsWorker) Work(ctx context.Context) {
+	log.Printf("[worker:tags] TagsWorker run")
+	for {
+		select {
+		case <-ctx.Done():
+			// Context was cancelled, stop the worker
+			return
+		default:
+			m.processTags(ctx)
+			// Sleep for the configured duration before checking for new media
+			select {
+			case <-time.After(time.Duration(m.cfg.SleepInterval) * time.Second):
+			case <-ctx.Done():
+				// If context is cancelled, stop sleeping and return
+				return
+			}
+			break
+		}
+	}
+}
+
+func (d *TagsWorker) processTags(ctx context.Context) {
+	// Fetch tagged media page
+	page, err := d.source.List(ctx, "")
+	if err != nil {
+		log.Printf("[worker:tags] Failed to fetch tagged media page: %v", err)
+		return
+	}
+
+	var items []*source.Item
+	for _, media := range page.Items {
+		if !d.allowed[strings.ToLower(media.Username)] {
+			log.Printf("[worker:tags] %s by @%s is not in the allow-list, skipping\n", media.ID, media.Username)
+			continue
+		}
+
+		caption, err := d.credit(media)
+		if err != nil {
+			log.Printf("[worker:tags] Failed to render caption: %v", err)
+			return
+		}
+		media.Caption = caption
+		items = append(items, media)
+	}
+
+	d.syncItems(ctx, "worker:tags", items)
+}
+
+// credit renders the caption crediting the original author of the media.
+func (d *TagsWorker) credit(media *source.Item) (string, error) {
+	var buf bytes.Buffer
+	if err := d.caption.Execute(&buf, media); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
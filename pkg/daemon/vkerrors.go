@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"log"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/vk"
+)
+
+// alertVKError reports VK errors that need a human, e.g. a revoked token or a
+// captcha. Pausing requests after such errors is up to the vk client.
+func alertVKError(worker string, err error) {
+	switch vk.KindOf(err) {
+	case vk.ErrorKindAuth, vk.ErrorKindCaptcha, vk.ErrorKindAccessDenied, vk.ErrorKindFlood:
+		log.Printf("[alert:vk] %s: %v", worker, err)
+	}
+}
+
+// vkPaused reports whether the vk client is paused, logging why.
+func vkPaused(worker string, vkClient *vk.Client) bool {
+	err := vkClient.Paused()
+	if err != nil {
+		log.Printf("[%s] Skipping cycle: %v", worker, err)
+	}
+	return err != nil
+}
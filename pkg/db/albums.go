@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// AlbumID returns the cached id of the VK album or 0 if it is not cached yet.
+func AlbumID(kind string, ownerID int, title string, db *sql.DB) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT album_id FROM albums WHERE kind = ? AND owner_id = ? AND title = ?",
+		kind, ownerID, title).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SaveAlbum caches the id of the VK album.
+func SaveAlbum(kind string, ownerID int, title string, albumID int, db *sql.DB) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO albums (kind, owner_id, title, album_id) VALUES (?, ?, ?, ?)",
+		kind, ownerID, title, albumID)
+	return err
+}
+
+// DeleteAlbum drops the cached id of the VK album.
+func DeleteAlbum(kind string, ownerID int, title string, db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM albums WHERE kind = ? AND owner_id = ? AND title = ?", kind, ownerID, title)
+	return err
+}
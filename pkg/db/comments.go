@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IsCommentMirrored reports whether the instagram comment was already posted
+// to VK.
+func IsCommentMirrored(id string, db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM comments WHERE id = ?", id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SaveComment records the VK comment the instagram comment was mirrored as.
+func SaveComment(id, mediaID string, vkCommentID int, db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO comments (id, media_id, vk_comment_id, mirrored_at) VALUES (?, ?, ?, ?)",
+		id, mediaID, vkCommentID, time.Now().Unix())
+	return err
+}
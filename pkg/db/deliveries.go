@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Delivery is the sync state of an item at one destination.
+type Delivery struct {
+	ID          string
+	Destination string
+	// Ref identifies the published object, empty until it is published
+	Ref   string
+	Error string
+	// Rejected is set when the destination will never take the item
+	Rejected bool
+	// Processing identifies an upload the destination is still processing
+	// since ProcessingSince
+	Processing      string
+	ProcessingSince time.Time
+	PublishedAt     time.Time
+	ExpiresAt       time.Time
+}
+
+// Deliveries returns the sync state of the item at every destination it was
+// published to or failed at.
+func Deliveries(id, table string, db *sql.DB) ([]Delivery, error) {
+	if err := checkTable(table); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT id, destination, COALESCE(ref, ''), COALESCE(error, ''), COALESCE(rejected, 0), COALESCE(processing_ref, ''), COALESCE(processing_since, 0), COALESCE(published_at, 0), COALESCE(expires_at, 0)
+		FROM deliveries WHERE id = ? AND table_name = ? ORDER BY destination`, id, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var (
+			d               Delivery
+			processingSince int64
+			publishedAt     int64
+			expiresAt       int64
+		)
+		if err := rows.Scan(&d.ID, &d.Destination, &d.Ref, &d.Error, &d.Rejected, &d.Processing, &processingSince, &publishedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if processingSince != 0 {
+			d.ProcessingSince = time.Unix(processingSince, 0)
+		}
+		if publishedAt != 0 {
+			d.PublishedAt = time.Unix(publishedAt, 0)
+		}
+		if expiresAt != 0 {
+			d.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// SaveDelivery records that the item was published to the destination.
+func SaveDelivery(id, table, destination, ref string, expiresAt time.Time, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	var expires sql.NullInt64
+	if !expiresAt.IsZero() {
+		expires = sql.NullInt64{Int64: expiresAt.Unix(), Valid: true}
+	}
+
+	_, err := db.Exec(`INSERT OR REPLACE INTO deliveries (id, table_name, destination, ref, error, published_at, expires_at)
+		VALUES (?, ?, ?, ?, NULL, ?, ?)`, id, table, destination, ref, time.Now().Unix(), expires)
+	return err
+}
+
+// SaveDeliveryError records why publishing the item to the destination
+// failed. The item stays pending for the destination.
+func SaveDeliveryError(id, table, destination, reason string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`INSERT OR REPLACE INTO deliveries (id, table_name, destination, ref, error)
+		VALUES (?, ?, ?, NULL, ?)`, id, table, destination, reason)
+	return err
+}
+
+// SaveDeliveryRejected records that the destination will never take the
+// item. The item is not retried for the destination.
+func SaveDeliveryRejected(id, table, destination, reason string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`INSERT OR REPLACE INTO deliveries (id, table_name, destination, ref, error, rejected)
+		VALUES (?, ?, ?, NULL, ?, 1)`, id, table, destination, reason)
+	return err
+}
+
+// SaveDeliveryProcessing records an upload the destination is still
+// processing. The item stays pending and the next attempt checks the upload.
+// The processing start of the same upload is kept across attempts.
+func SaveDeliveryProcessing(id, table, destination, processingRef, reason string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`INSERT OR REPLACE INTO deliveries (id, table_name, destination, ref, error, processing_ref, processing_since)
+		VALUES (?, ?, ?, NULL, ?, ?, COALESCE(
+			(SELECT processing_since FROM deliveries WHERE id = ? AND table_name = ? AND destination = ? AND processing_ref = ?), ?))`,
+		id, table, destination, reason, processingRef, id, table, destination, processingRef, time.Now().Unix())
+	return err
+}
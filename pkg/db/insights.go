@@ -0,0 +1,32 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Platforms insights are collected from.
+const (
+	PlatformInstagram = "instagram"
+	PlatformVK        = "vk"
+)
+
+// SaveMetrics appends a sample of the item metrics on the platform to the
+// insights time series.
+func SaveMetrics(id, platform string, metrics map[string]int, at time.Time, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for metric, value := range metrics {
+		_, err = tx.Exec("INSERT INTO insights (id, platform, metric, value, collected_at) VALUES (?, ?, ?, ?, ?)",
+			id, platform, metric, value, at.Unix())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
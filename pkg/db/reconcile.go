@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// States of the VK object after its instagram original disappeared.
+const (
+	VKStateDeleted  = "deleted"
+	VKStateArchived = "archived"
+	VKStateFlagged  = "flagged"
+)
+
+// SetMissing records when the item was found missing on instagram. A zero
+// time clears the mark once the item shows up again.
+func SetMissing(id, table string, at time.Time, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	var missingSince sql.NullInt64
+	if !at.IsZero() {
+		missingSince = sql.NullInt64{Int64: at.Unix(), Valid: true}
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET missing_since = ? WHERE id = ?", table)
+	_, err := db.Exec(query, missingSince, id)
+	return err
+}
+
+// SetVKState records what happened to the VK object of a missing item.
+func SetVKState(id, table, state string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET vk_state = ? WHERE id = ?", table)
+	_, err := db.Exec(query, state, id)
+	return err
+}
@@ -1,22 +1,35 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
 )
 
+// syncTables holds a sync table per source kind.
+var syncTables = []string{"media", "stories", "tags", "folder", "feed", "archive"}
+
+func checkTable(table string) error {
+	// check if table name is valid
+	for _, t := range syncTables {
+		if t == table {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid table name: %s", table)
+}
+
 func CheckAndInsert(id, table string, db *sql.DB) (bool, error) {
 	var synced bool
 
-	// check if table name is valid
-	switch table {
-	case "media", "stories":
-		// valid table name, do nothing
-	default:
-		return false, fmt.Errorf("Invalid table name: %s", table)
+	if err := checkTable(table); err != nil {
+		return false, err
 	}
 
 	query := fmt.Sprintf("SELECT synced FROM %s WHERE id = ?", table)
@@ -37,13 +50,295 @@ func CheckAndInsert(id, table string, db *sql.DB) (bool, error) {
 	return synced, nil
 }
 
+// SaveItem stores the metadata of the item in its sync record.
+func SaveItem(item *source.Item, table string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	var timestamp sql.NullInt64
+	if !item.Timestamp.IsZero() {
+		timestamp = sql.NullInt64{Int64: item.Timestamp.Unix(), Valid: true}
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET timestamp = ?, media_type = ?, media_url = ?, caption = ?, thumbnail_url = ?,
+		username = ?, shortcode = ?, caption_hash = ? WHERE id = ?`, table)
+	_, err := db.Exec(query, timestamp, item.MediaType, item.MediaURL, item.Caption, item.ThumbnailURL,
+		item.Username, item.Shortcode, CaptionHash(item.Caption), item.ID)
+	return err
+}
+
+// RegisterItem adds the item to the table unless it is already there. It
+// reports whether the item was added.
+func RegisterItem(item *source.Item, table string, db *sql.DB) (bool, error) {
+	if err := checkTable(table); err != nil {
+		return false, err
+	}
+
+	var count int
+	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ?", table), item.ID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := CheckAndInsert(item.ID, table, db); err != nil {
+		return false, err
+	}
+	return true, SaveItem(item, table, db)
+}
+
+// PendingItems returns up to limit items of the table that are not synced
+// yet and have not failed, oldest first.
+func PendingItems(table string, limit int, db *sql.DB) ([]*source.Item, error) {
+	if err := checkTable(table); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, COALESCE(caption, ''), COALESCE(media_type, ''), COALESCE(media_url, ''), COALESCE(timestamp, 0)
+		FROM %s WHERE synced = 0 AND failed_at IS NULL ORDER BY timestamp LIMIT ?`, table)
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*source.Item
+	for rows.Next() {
+		var (
+			item      source.Item
+			timestamp int64
+		)
+		err := rows.Scan(&item.ID, &item.Caption, &item.MediaType, &item.MediaURL, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if timestamp != 0 {
+			item.Timestamp = time.Unix(timestamp, 0)
+		}
+		items = append(items, &item)
+	}
+
+	return items, rows.Err()
+}
+
+// CaptionHash returns the hash of the caption stored to detect edits.
+func CaptionHash(caption string) string {
+	sum := sha256.Sum256([]byte(caption))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpdateCaptionHash stores the hash of the caption the item currently has on
+// VK.
+func UpdateCaptionHash(id, table, hash string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET caption_hash = ? WHERE id = ?", table)
+	_, err := db.Exec(query, hash, id)
+	return err
+}
+
+// SaveVKObject stores which VK object (wall post, video or story) the item was
+// published as.
+func SaveVKObject(id, table, vkType string, ownerID, vkID int, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET vk_type = ?, vk_owner_id = ?, vk_id = ? WHERE id = ?", table)
+	_, err := db.Exec(query, vkType, ownerID, vkID, id)
+	return err
+}
+
+// SaveVKExpiry stores when the VK object (a story) expires.
+func SaveVKExpiry(id, table string, expiresAt time.Time, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET vk_expires_at = ? WHERE id = ?", table)
+	_, err := db.Exec(query, expiresAt.Unix(), id)
+	return err
+}
+
+// SavePublishAt stores when the VK post of the item is (or was) published.
+func SavePublishAt(id, table string, publishAt time.Time, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET vk_publish_at = ? WHERE id = ?", table)
+	_, err := db.Exec(query, publishAt.Unix(), id)
+	return err
+}
+
+// PublishAt returns the saved publish time of the item, zero if there is
+// none.
+func PublishAt(id, table string, db *sql.DB) (time.Time, error) {
+	if err := checkTable(table); err != nil {
+		return time.Time{}, err
+	}
+
+	var publishAt sql.NullInt64
+	query := fmt.Sprintf("SELECT vk_publish_at FROM %s WHERE id = ?", table)
+	err := db.QueryRow(query, id).Scan(&publishAt)
+	if err == sql.ErrNoRows || !publishAt.Valid {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(publishAt.Int64, 0), nil
+}
+
+// ClearPublishAt drops the publish time of the item, e.g. a slot reserved
+// for a post that failed.
+func ClearPublishAt(id, table string, db *sql.DB) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET vk_publish_at = NULL WHERE id = ?", table)
+	_, err := db.Exec(query, id)
+	return err
+}
+
+// PublishTimes returns publish times of VK posts of all sync tables after
+// since, including postponed ones.
+func PublishTimes(since time.Time, db *sql.DB) ([]time.Time, error) {
+	var times []time.Time
+	for _, table := range syncTables {
+		query := fmt.Sprintf("SELECT vk_publish_at FROM %s WHERE vk_publish_at >= ?", table)
+		rows, err := db.Query(query, since.Unix())
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var publishAt int64
+			if err := rows.Scan(&publishAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			times = append(times, time.Unix(publishAt, 0))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return times, nil
+}
+
+// Record is a synced item together with the VK object it was published as.
+type Record struct {
+	ID        string
+	MediaType string
+	Timestamp time.Time
+	// VKType, VKOwnerID and VKID are empty for items not published to VK
+	VKType      string
+	VKOwnerID   int
+	VKID        int
+	CaptionHash string
+	// MissingSince is when the item was first found missing on instagram,
+	// zero while it is there
+	MissingSince time.Time
+	// VKExpiresAt is when the VK story expires, zero for other objects
+	VKExpiresAt time.Time
+}
+
+// SyncedRecords returns synced items created after since whose published
+// copies are still in place.
+func SyncedRecords(table string, since time.Time, db *sql.DB) ([]Record, error) {
+	if err := checkTable(table); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, COALESCE(media_type, ''), COALESCE(timestamp, 0), COALESCE(vk_type, ''), COALESCE(vk_owner_id, 0), COALESCE(vk_id, 0),
+		COALESCE(caption_hash, ''), COALESCE(missing_since, 0), COALESCE(vk_expires_at, 0)
+		FROM %s WHERE synced = 1 AND vk_state IS NULL AND COALESCE(timestamp, 0) >= ?
+		ORDER BY timestamp`, table)
+	rows, err := db.Query(query, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var (
+			r            Record
+			timestamp    int64
+			missingSince int64
+			vkExpiresAt  int64
+		)
+		err := rows.Scan(&r.ID, &r.MediaType, &timestamp, &r.VKType, &r.VKOwnerID, &r.VKID, &r.CaptionHash, &missingSince, &vkExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Timestamp = time.Unix(timestamp, 0)
+		if missingSince != 0 {
+			r.MissingSince = time.Unix(missingSince, 0)
+		}
+		if vkExpiresAt != 0 {
+			r.VKExpiresAt = time.Unix(vkExpiresAt, 0)
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// SaveAttempt records a failed attempt to sync the item. Once the item
+// used up maxAttempts it is marked failed, which it reports.
+func SaveAttempt(id, table, reason string, maxAttempts int, db *sql.DB) (bool, error) {
+	if err := checkTable(table); err != nil {
+		return false, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET attempts = COALESCE(attempts, 0) + 1, last_error = ? WHERE id = ?", table)
+	if _, err := db.Exec(query, reason, id); err != nil {
+		return false, err
+	}
+
+	var attempts int
+	query = fmt.Sprintf("SELECT COALESCE(attempts, 0) FROM %s WHERE id = ?", table)
+	if err := db.QueryRow(query, id).Scan(&attempts); err != nil {
+		return false, err
+	}
+	if attempts < maxAttempts {
+		return false, nil
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET failed_at = ? WHERE id = ?", table)
+	_, err := db.Exec(query, time.Now().Unix(), id)
+	return true, err
+}
+
+// IsFailed reports whether the item used up its attempts and is not tried
+// anymore.
+func IsFailed(id, table string, db *sql.DB) (bool, error) {
+	if err := checkTable(table); err != nil {
+		return false, err
+	}
+
+	var failed bool
+	query := fmt.Sprintf("SELECT failed_at IS NOT NULL FROM %s WHERE id = ?", table)
+	err := db.QueryRow(query, id).Scan(&failed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return failed, err
+}
+
 func MarkAsSynced(id, table string, db *sql.DB) error {
-	// check if table name is valid
-	switch table {
-	case "media", "stories":
-		// valid table name, do nothing
-	default:
-		return fmt.Errorf("Invalid table name: %s", table)
+	if err := checkTable(table); err != nil {
+		return err
 	}
 
 	// If media is successfully uploaded, update the media record as synced in the database
@@ -57,19 +352,114 @@ func MarkAsSynced(id, table string, db *sql.DB) error {
 
 }
 
+// ensureColumn adds the column to the table unless it is already there, so
+// databases created by older versions keep working.
+func ensureColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notnull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func SetupDB(database string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", database)
 	if err != nil {
 		return nil, err
 	}
 
-	statement, _ := db.Prepare("CREATE TABLE IF NOT EXISTS media (id TEXT, synced BOOLEAN DEFAULT 0)")
-	_, err = statement.Exec()
+	for _, table := range syncTables {
+		_, err = db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id TEXT, synced BOOLEAN DEFAULT 0)", table))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// media metadata, added after the initial schema
+	columns := []struct{ name, definition string }{
+		{"timestamp", "INTEGER"},
+		{"thumbnail_url", "TEXT"},
+		{"username", "TEXT"},
+		{"shortcode", "TEXT"},
+		{"media_type", "TEXT"},
+		{"media_url", "TEXT"},
+		{"caption", "TEXT"},
+		{"vk_type", "TEXT"},
+		{"vk_owner_id", "INTEGER"},
+		{"vk_id", "INTEGER"},
+		{"caption_hash", "TEXT"},
+		{"missing_since", "INTEGER"},
+		{"vk_state", "TEXT"},
+		{"vk_expires_at", "INTEGER"},
+		{"vk_publish_at", "INTEGER"},
+		{"attempts", "INTEGER DEFAULT 0"},
+		{"last_error", "TEXT"},
+		{"failed_at", "INTEGER"},
+	}
+	for _, table := range syncTables {
+		for _, c := range columns {
+			if err := ensureColumn(db, table, c.name, c.definition); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS insights (id TEXT, platform TEXT, metric TEXT, value INTEGER, collected_at INTEGER)")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS comments (id TEXT PRIMARY KEY, media_id TEXT, vk_comment_id INTEGER, mirrored_at INTEGER)")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS deliveries (id TEXT, table_name TEXT, destination TEXT, ref TEXT, error TEXT,
+		published_at INTEGER, expires_at INTEGER, PRIMARY KEY (id, table_name, destination))`)
 	if err != nil {
 		return nil, err
 	}
-	statement, _ = db.Prepare("CREATE TABLE IF NOT EXISTS stories (id TEXT, synced BOOLEAN DEFAULT 0)")
-	_, err = statement.Exec()
+	if err := ensureColumn(db, "deliveries", "rejected", "INTEGER DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := ensureColumn(db, "deliveries", "processing_ref", "TEXT"); err != nil {
+		return nil, err
+	}
+	if err := ensureColumn(db, "deliveries", "processing_since", "INTEGER"); err != nil {
+		return nil, err
+	}
+	// items published before destinations were tracked separately only went
+	// to VK, their refs follow the VK attachment format, e.g. wall-1_2
+	for _, table := range syncTables {
+		_, err = db.Exec(fmt.Sprintf(`INSERT OR IGNORE INTO deliveries (id, table_name, destination, ref, published_at, expires_at)
+			SELECT id, ?, 'vk', vk_type || vk_owner_id || '_' || vk_id, vk_publish_at, vk_expires_at
+			FROM %s WHERE synced = 1 AND vk_id IS NOT NULL`, table), table)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS albums (kind TEXT, owner_id INTEGER, title TEXT, album_id INTEGER, PRIMARY KEY (kind, owner_id, title))")
 	if err != nil {
 		return nil, err
 	}
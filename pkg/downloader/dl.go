@@ -10,7 +10,7 @@ import (
 const maxRetries = 3
 const delayBetweenRetries = time.Second * 5
 
-func DownloadFile(url string) (io.Reader, error) {
+func DownloadFile(url string) (io.ReadCloser, error) {
 	var resp *http.Response
 	var err error
 	for i := 0; i < maxRetries; i++ {
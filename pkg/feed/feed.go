@@ -0,0 +1,196 @@
+package feed
+
+import (
+	"encoding/xml"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry is a feed entry in a format independent way.
+type Entry struct {
+	GUID       string
+	Title      string
+	Summary    string
+	Link       string
+	Published  time.Time
+	Enclosures []Enclosure
+}
+
+type Enclosure struct {
+	URL  string
+	Type string
+}
+
+// addEnclosure adds the enclosure unless the entry has one of the same url
+// already, feeds often list an image both as <enclosure> and <media:content>.
+func (e *Entry) addEnclosure(enc Enclosure) {
+	enc.URL = strings.TrimSpace(enc.URL)
+	if enc.URL == "" {
+		return
+	}
+	for i := range e.Enclosures {
+		if e.Enclosures[i].URL == enc.URL {
+			if e.Enclosures[i].Type == "" {
+				e.Enclosures[i].Type = enc.Type
+			}
+			return
+		}
+	}
+	e.Enclosures = append(e.Enclosures, enc)
+}
+
+type rss struct {
+	Channel struct {
+		Items []struct {
+			GUID        string `xml:"guid"`
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+			Link        string `xml:"link"`
+			PubDate     string `xml:"pubDate"`
+			Enclosures  []struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+			MediaContent []struct {
+				URL    string `xml:"url,attr"`
+				Type   string `xml:"type,attr"`
+				Medium string `xml:"medium,attr"`
+			} `xml:"http://search.yahoo.com/mrss/ content"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atom struct {
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Links     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// dateLayouts are tried in order to parse entry dates.
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// Parse reads an RSS 2.0 or Atom document.
+func Parse(data []byte) ([]Entry, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	if root.XMLName.Local == "feed" {
+		return parseAtom(data)
+	}
+	return parseRSS(data)
+}
+
+func parseRSS(data []byte) ([]Entry, error) {
+	var doc rss
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, item := range doc.Channel.Items {
+		e := Entry{
+			GUID:      strings.TrimSpace(item.GUID),
+			Title:     strings.TrimSpace(item.Title),
+			Summary:   plainText(item.Description),
+			Link:      strings.TrimSpace(item.Link),
+			Published: parseDate(item.PubDate),
+		}
+		if e.GUID == "" {
+			e.GUID = e.Link
+		}
+		for _, enc := range item.Enclosures {
+			e.addEnclosure(Enclosure{URL: enc.URL, Type: enc.Type})
+		}
+		for _, mc := range item.MediaContent {
+			typ := mc.Type
+			if typ == "" && mc.Medium != "" {
+				typ = mc.Medium + "/"
+			}
+			e.addEnclosure(Enclosure{URL: mc.URL, Type: typ})
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseAtom(data []byte) ([]Entry, error) {
+	var doc atom
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, entry := range doc.Entries {
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+
+		e := Entry{
+			GUID:      strings.TrimSpace(entry.ID),
+			Title:     plainText(entry.Title),
+			Summary:   plainText(summary),
+			Published: parseDate(published),
+		}
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if e.Link == "" {
+					e.Link = link.Href
+				}
+			case "enclosure":
+				e.addEnclosure(Enclosure{URL: link.Href, Type: link.Type})
+			}
+		}
+		if e.GUID == "" {
+			e.GUID = e.Link
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// plainText strips html markup of feed summaries.
+func plainText(value string) string {
+	return strings.TrimSpace(html.UnescapeString(tags.ReplaceAllString(value, "")))
+}
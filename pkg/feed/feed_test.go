@@ -0,0 +1,164 @@
+package feed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const rssSample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Blog</title>
+    <item>
+      <guid>post-1</guid>
+      <title> First post </title>
+      <description><![CDATA[<p>Hello &amp; <b>welcome</b></p>]]></description>
+      <link>https://blog.example/1</link>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+      <enclosure url="https://blog.example/1.jpg" type="image/jpeg" length="1"/>
+      <media:content url="https://blog.example/1.jpg" medium="image"/>
+      <media:content url="https://blog.example/1.mp4" medium="video"/>
+    </item>
+    <item>
+      <title>No guid</title>
+      <link>https://blog.example/2</link>
+      <pubDate>Tue, 3 Jan 2006 10:00:00 GMT</pubDate>
+      <media:content url="https://blog.example/2.png" medium="image"/>
+      <enclosure url="https://blog.example/2.png" type="image/png"/>
+    </item>
+    <item>
+      <guid>post-3</guid>
+      <title>Bad date</title>
+      <pubDate>yesterday</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+const atomSample = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog</title>
+  <entry>
+    <id>urn:entry:1</id>
+    <title type="html">Atom &lt;i&gt;post&lt;/i&gt;</title>
+    <summary>Short &amp; sweet</summary>
+    <published>2006-01-02T15:04:05Z</published>
+    <updated>2006-01-05T00:00:00Z</updated>
+    <link rel="alternate" href="https://blog.example/a"/>
+    <link rel="enclosure" href="https://blog.example/a.jpg" type="image/jpeg"/>
+    <link rel="enclosure" href="https://blog.example/a.jpg" type="image/jpeg"/>
+  </entry>
+  <entry>
+    <title>Updated only</title>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+    <updated>2006-01-03T00:00:00+02:00</updated>
+    <link href="https://blog.example/b"/>
+  </entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Entry
+	}{
+		{
+			name: "rss",
+			data: rssSample,
+			want: []Entry{
+				{
+					GUID:      "post-1",
+					Title:     "First post",
+					Summary:   "Hello & welcome",
+					Link:      "https://blog.example/1",
+					Published: time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC),
+					Enclosures: []Enclosure{
+						{URL: "https://blog.example/1.jpg", Type: "image/jpeg"},
+						{URL: "https://blog.example/1.mp4", Type: "video/"},
+					},
+				},
+				{
+					GUID:      "https://blog.example/2",
+					Title:     "No guid",
+					Link:      "https://blog.example/2",
+					Published: time.Date(2006, 1, 3, 10, 0, 0, 0, time.UTC),
+					Enclosures: []Enclosure{
+						{URL: "https://blog.example/2.png", Type: "image/png"},
+					},
+				},
+				{
+					GUID:  "post-3",
+					Title: "Bad date",
+				},
+			},
+		},
+		{
+			name: "atom",
+			data: atomSample,
+			want: []Entry{
+				{
+					GUID:      "urn:entry:1",
+					Title:     "Atom post",
+					Summary:   "Short & sweet",
+					Link:      "https://blog.example/a",
+					Published: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+					Enclosures: []Enclosure{
+						{URL: "https://blog.example/a.jpg", Type: "image/jpeg"},
+					},
+				},
+				{
+					GUID:      "https://blog.example/b",
+					Title:     "Updated only",
+					Summary:   "Body",
+					Link:      "https://blog.example/b",
+					Published: time.Date(2006, 1, 2, 22, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !got[i].Published.Equal(tt.want[i].Published) {
+					t.Errorf("entry %d published %s, want %s", i, got[i].Published, tt.want[i].Published)
+				}
+				got[i].Published, tt.want[i].Published = time.Time{}, time.Time{}
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 02 Jan 2006 15:04:05 UTC", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{" 2006-01-02T15:04:05+01:00 ", time.Date(2006, 1, 2, 14, 4, 5, 0, time.UTC)},
+		{"2006-01-02", time.Time{}},
+		{"", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseDate(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte("not xml")); err == nil {
+		t.Error("Parse of garbage succeeded")
+	}
+}
@@ -0,0 +1,207 @@
+package feed
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Source implements the source.Source interface.
+var _ source.Source = (*Source)(nil)
+
+// Source reads entries of RSS and Atom feeds. Item ids are SHA-1 hashes of
+// entry GUIDs, so they are stable and safe to use as storage object names.
+type Source struct {
+	httpClient *http.Client
+	urls       []string
+	limit      int
+
+	mu sync.Mutex
+	// firstSeen holds when entries without a date were first listed, they
+	// are ordered by it instead
+	firstSeen map[string]time.Time
+}
+
+func NewSource(config config.FeedConfig) *Source {
+	// set default fallback values
+	if config.LastEntriesCount == 0 {
+		config.LastEntriesCount = 5
+	}
+
+	return &Source{
+		httpClient: &http.Client{},
+		urls:       config.URLs,
+		limit:      config.LastEntriesCount,
+		firstSeen:  make(map[string]time.Time),
+	}
+}
+
+func (s *Source) Kind() source.Kind {
+	return source.KindFeed
+}
+
+// List returns the latest entries of all feeds in one page. A feed that
+// fails is logged and skipped, the listing only fails if every feed does.
+func (s *Source) List(ctx context.Context, cursor string) (*source.Page, error) {
+	page := &source.Page{}
+	var errs []error
+	for _, url := range s.urls {
+		entries, err := s.fetch(ctx, url)
+		if err != nil {
+			log.Printf("[source:feed] Skipping feed %s: %v\n", url, err)
+			errs = append(errs, fmt.Errorf("feed %s: %w", url, err))
+			continue
+		}
+
+		for i, e := range entries {
+			if i >= s.limit {
+				break
+			}
+			page.Items = append(page.Items, s.item(e))
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(s.urls) {
+		return nil, errors.Join(errs...)
+	}
+
+	source.SortItems(page.Items)
+	return page, nil
+}
+
+func (s *Source) Detail(ctx context.Context, id string) (*source.Item, error) {
+	for _, url := range s.urls {
+		entries, err := s.fetch(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", url, err)
+		}
+
+		for _, e := range entries {
+			if ItemID(e.GUID) == id {
+				return s.item(e), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("feed entry %s not found", id)
+}
+
+func (s *Source) Download(ctx context.Context, item *source.Item) (io.ReadCloser, error) {
+	if item.MediaURL == "" {
+		return nil, fmt.Errorf("no enclosure for feed entry: %s", item.ID)
+	}
+	return downloader.DownloadFile(item.MediaURL)
+}
+
+func (s *Source) fetch(ctx context.Context, url string) ([]Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(body)
+}
+
+// ItemID derives the item id from the entry GUID.
+func ItemID(guid string) string {
+	sum := sha1.Sum([]byte(guid))
+	return hex.EncodeToString(sum[:])
+}
+
+// item converts the entry into a source.Item. Image and video enclosures
+// become the item media, several of them a carousel.
+func (s *Source) item(e Entry) *source.Item {
+	var parts []string
+	for _, part := range []string{e.Title, e.Summary, e.Link} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	it := &source.Item{
+		ID:        ItemID(e.GUID),
+		Caption:   strings.Join(parts, "\n\n"),
+		MediaType: source.MediaTypeText,
+		Permalink: e.Link,
+		Timestamp: e.Published,
+	}
+	if it.Timestamp.IsZero() {
+		it.Timestamp = s.seen(it.ID)
+	}
+
+	var media []*source.Item
+	for _, enc := range e.Enclosures {
+		mediaType := enclosureType(enc.Type)
+		if mediaType == "" {
+			continue
+		}
+		media = append(media, &source.Item{
+			ID:        ItemID(enc.URL),
+			MediaType: mediaType,
+			MediaURL:  enc.URL,
+			Timestamp: it.Timestamp,
+		})
+	}
+
+	switch {
+	case len(media) == 1:
+		it.MediaType, it.MediaURL = media[0].MediaType, media[0].MediaURL
+	case len(media) > 1:
+		it.MediaType, it.MediaURL = source.MediaTypeCarousel, media[0].MediaURL
+		it.Children = media
+	}
+
+	return it
+}
+
+// seen returns when the entry was first listed. Keeping it in memory is
+// enough for ordering, entries are stored once they are listed.
+func (s *Source) seen(id string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.firstSeen[id]
+	if !ok {
+		t = time.Now()
+		s.firstSeen[id] = t
+	}
+	return t
+}
+
+func enclosureType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return source.MediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return source.MediaTypeVideo
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,164 @@
+package folder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+	"gopkg.in/yaml.v3"
+)
+
+// Ensure that Source implements the source.Source interface.
+var _ source.Source = (*Source)(nil)
+
+// sidecarExts are checked in order for a caption file next to the media.
+var sidecarExts = []string{".yaml", ".yml", ".json"}
+
+// Sidecar holds metadata of a media file stored next to it, e.g. photo.jpg
+// and photo.yaml.
+type Sidecar struct {
+	Caption string `yaml:"caption" json:"caption"`
+}
+
+// Source polls a local directory for media files. Item ids are file paths
+// relative to the directory.
+type Source struct {
+	path   string
+	settle time.Duration
+}
+
+func NewSource(config config.FolderConfig) *Source {
+	// set default fallback values
+	if config.SettleTime == 0 {
+		config.SettleTime = 10
+	}
+
+	return &Source{
+		path:   config.Path,
+		settle: time.Duration(config.SettleTime) * time.Second,
+	}
+}
+
+func (s *Source) Kind() source.Kind {
+	return source.KindFolder
+}
+
+// List returns all settled media files of the directory in one page. Files
+// that can't be read, or whose sidecar is broken, are logged and skipped, so
+// one bad file doesn't hold back the others. They are picked up again once
+// fixed.
+func (s *Source) List(ctx context.Context, cursor string) (*source.Page, error) {
+	page := &source.Page{}
+	err := filepath.WalkDir(s.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the directory itself must be readable
+			if path == s.path {
+				return err
+			}
+			log.Printf("[source:folder] Skipping %s: %v\n", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if source.MediaTypeOf(path) == "" {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			log.Printf("[source:folder] Skipping %s: %v\n", path, err)
+			return nil
+		}
+		if time.Since(info.ModTime()) < s.settle {
+			return nil
+		}
+
+		id, err := filepath.Rel(s.path, path)
+		if err != nil {
+			return err
+		}
+		item, err := s.item(id, info)
+		if err != nil {
+			log.Printf("[source:folder] Skipping %s: %v\n", path, err)
+			return nil
+		}
+		page.Items = append(page.Items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	source.SortItems(page.Items)
+	return page, nil
+}
+
+func (s *Source) Detail(ctx context.Context, id string) (*source.Item, error) {
+	info, err := os.Stat(filepath.Join(s.path, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.item(id, info)
+}
+
+func (s *Source) Download(ctx context.Context, item *source.Item) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.path, item.ID))
+}
+
+func (s *Source) item(id string, info fs.FileInfo) (*source.Item, error) {
+	mediaType := source.MediaTypeOf(id)
+	if mediaType == "" {
+		return nil, fmt.Errorf("unsupported media file: %s", id)
+	}
+
+	sidecar, err := s.sidecar(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &source.Item{
+		ID:        filepath.ToSlash(id),
+		Caption:   sidecar.Caption,
+		MediaType: mediaType,
+		Timestamp: info.ModTime(),
+	}, nil
+}
+
+// sidecar reads the caption file of the media, if there is one.
+func (s *Source) sidecar(id string) (*Sidecar, error) {
+	base := strings.TrimSuffix(filepath.Join(s.path, id), filepath.Ext(id))
+
+	var sidecar Sidecar
+	for _, ext := range sidecarExts {
+		data, err := os.ReadFile(base + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if ext == ".json" {
+			err = json.Unmarshal(data, &sidecar)
+		} else {
+			err = yaml.Unmarshal(data, &sidecar)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sidecar %s: %w", base+ext, err)
+		}
+		break
+	}
+
+	return &sidecar, nil
+}
@@ -7,29 +7,39 @@ import (
 	"github.com/s-yakubovskiy/inst2vk/pkg/config"
 )
 
-type MediaFetcher interface {
-	FetchMediaIds() ([]string, error)
-	FetchMediaDetail(id string) (MediaDetail, error)
+type MediaResponse struct {
+	Data   []MediaDetail `json:"data"`
+	Paging Paging        `json:"paging"`
 }
 
-type MediaResponse struct {
-	Data []struct {
-		ID string `json:"id"`
-	} `json:"data"`
+type Paging struct {
+	Cursors struct {
+		Before string `json:"before"`
+		After  string `json:"after"`
+	} `json:"cursors"`
+	Next string `json:"next"`
 }
 
 type MediaDetail struct {
-	MediaURL  string `json:"media_url"`
-	Caption   string `json:"caption"`
-	ID        string `json:"id"`
-	MediaType string `json:"media_type"`
-	Permalink string `json:"permalink"`
+	MediaURL     string `json:"media_url"`
+	Caption      string `json:"caption"`
+	ID           string `json:"id"`
+	MediaType    string `json:"media_type"`
+	Permalink    string `json:"permalink"`
+	Timestamp    string `json:"timestamp"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Username     string `json:"username"`
+	Shortcode    string `json:"shortcode"`
+	Children     struct {
+		Data []MediaDetail `json:"data"`
+	} `json:"children"`
 }
 
 type Client struct {
 	httpClient   *http.Client
 	limitStories int
 	limitPosts   int
+	limitTags    int
 	token        string
 	api          string
 	id           string
@@ -49,6 +59,9 @@ func NewClient(config config.InstagramConfig) *Client {
 	if config.LastPostsCount == 0 {
 		config.LastPostsCount = 3
 	}
+	if config.Tags.LastTagsCount == 0 {
+		config.Tags.LastTagsCount = 3
+	}
 
 	return &Client{
 		httpClient:   &http.Client{},
@@ -57,5 +70,6 @@ func NewClient(config config.InstagramConfig) *Client {
 		id:           config.AccountID,
 		limitStories: config.LastStoriesCount,
 		limitPosts:   config.LastPostsCount,
+		limitTags:    config.Tags.LastTagsCount,
 	}
 }
@@ -0,0 +1,39 @@
+package instagram
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+type Comment struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Username  string `json:"username"`
+	Timestamp string `json:"timestamp"`
+}
+
+type CommentsResponse struct {
+	Data []Comment `json:"data"`
+}
+
+// FetchComments returns top level comments of the media, oldest first.
+func (c *Client) FetchComments(ctx context.Context, mediaID string) ([]Comment, error) {
+	q := url.Values{}
+	q.Set("access_token", c.token)
+	q.Set("fields", "id,text,username,timestamp")
+	q.Set("limit", "50")
+
+	var comments CommentsResponse
+	err := c.getJSON(ctx, fmt.Sprintf("%s/%s/comments?%s", c.api, mediaID, q.Encode()), &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(comments.Data, func(i, j int) bool {
+		return comments.Data[i].Timestamp < comments.Data[j].Timestamp
+	})
+
+	return comments.Data, nil
+}
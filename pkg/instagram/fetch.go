@@ -1,10 +1,14 @@
 package instagram
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func (c *Client) getLimits(field string) int {
@@ -14,57 +18,138 @@ func (c *Client) getLimits(field string) int {
 	if field == "stories" {
 		return c.limitStories
 	}
+	if field == "tags" {
+		return c.limitTags
+	}
 	return 3
 }
 
-func (c *Client) FetchMediaIds(field string) ([]string, error) {
-	limiter := c.getLimits(field)
-	url := fmt.Sprintf("%s/%s/%s?access_token=%s", c.api, c.id, field, c.token)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+// mediaFields is requested both for single lookups and, through field
+// expansion, for whole edges so a page carries full details in one call.
+const mediaFields = "id,caption,media_type,media_url,permalink,timestamp,thumbnail_url,username,shortcode,children{id,media_type,media_url,permalink,thumbnail_url}"
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+// FetchMediaPage returns media of the given edge (media or stories) starting
+// at the after cursor, oldest first, together with the cursor of the next page.
+func (c *Client) FetchMediaPage(ctx context.Context, field, after string) ([]MediaDetail, string, error) {
+	limiter := c.getLimits(field)
+	q := url.Values{}
+	q.Set("access_token", c.token)
+	q.Set("fields", mediaFields)
+	q.Set("limit", fmt.Sprint(limiter))
+	if after != "" {
+		q.Set("after", after)
 	}
-	defer resp.Body.Close()
+	reqURL := fmt.Sprintf("%s/%s/%s?%s", c.api, c.id, field, q.Encode())
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	// api errors, e.g. an expired token, must not look like an empty page
 	var media MediaResponse
-	json.Unmarshal(body, &media)
+	if err := c.getJSON(ctx, reqURL, &media); err != nil {
+		return nil, "", err
+	}
 
-	var ids []string
+	var items []MediaDetail
 	for i, m := range media.Data {
 		if i >= limiter {
 			break
 		}
-		ids = append(ids, m.ID)
+		items = append(items, m)
+	}
+
+	var next string
+	if media.Paging.Next != "" {
+		next = media.Paging.Cursors.After
 	}
 
-	return reverseSlice(ids), nil
+	return reverseSlice(items), next, nil
 }
 
-func (c *Client) FetchMediaDetail(id string) (*MediaDetail, error) {
-	url := fmt.Sprintf("%s/%s?fields=media_url,caption,id,media_type,permalink&access_token=%s", c.api, id, c.token)
-	req, err := http.NewRequest("GET", url, nil)
+// FetchMediaDetail returns details of the media. The error satisfies
+// IsNotFound when the media was deleted or archived.
+func (c *Client) FetchMediaDetail(ctx context.Context, id string) (*MediaDetail, error) {
+	url := fmt.Sprintf("%s/%s?fields=%s&access_token=%s", c.api, id, mediaFields, c.token)
+
+	var mediaDetail MediaDetail
+	err := c.getJSON(ctx, url, &mediaDetail)
 	if err != nil {
 		return nil, err
 	}
+	if mediaDetail.MediaURL == "" {
+		return nil, fmt.Errorf("No media url for id: %+v\n", id)
+	}
+
+	return &mediaDetail, nil
+}
+
+// GraphError is the error object graph api returns instead of data.
+type GraphError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+	Type       string `json:"type"`
+	Code       int    `json:"code"`
+	Subcode    int    `json:"error_subcode"`
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph api error %d (%s): %s", e.Code, e.Type, e.Message)
+}
+
+// IsNotFound reports whether the error means the requested object does not
+// exist (anymore) or is not accessible to us.
+func IsNotFound(err error) bool {
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+	// code 100 with subcode 33 is "object does not exist"
+	return graphErr.StatusCode == http.StatusNotFound || (graphErr.Code == 100 && graphErr.Subcode == 33)
+}
+
+// getJSON performs a GET request to graph api and decodes the response into v.
+func (c *Client) getJSON(ctx context.Context, reqURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return err
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	var mediaDetail MediaDetail
-	json.Unmarshal(body, &mediaDetail)
-	if mediaDetail.MediaURL == "" {
-		return nil, fmt.Errorf("No media url for id: %+v\n", id)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
 	}
 
-	return &mediaDetail, nil
+	var graphErr struct {
+		Error *GraphError `json:"error"`
+	}
+	json.Unmarshal(body, &graphErr)
+	if graphErr.Error != nil {
+		graphErr.Error.StatusCode = resp.StatusCode
+		return graphErr.Error
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return &GraphError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// FetchMediaDetails looks up several media at once through the ids batch
+// lookup. Graph api fails the whole batch if any of the ids is unavailable.
+func (c *Client) FetchMediaDetails(ctx context.Context, ids []string) (map[string]MediaDetail, error) {
+	q := url.Values{}
+	q.Set("access_token", c.token)
+	q.Set("fields", mediaFields)
+	q.Set("ids", strings.Join(ids, ","))
+
+	details := make(map[string]MediaDetail)
+	err := c.getJSON(ctx, fmt.Sprintf("%s/?%s", c.api, q.Encode()), &details)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, nil
 }
@@ -1,7 +1,16 @@
 package instagram
 
-func reverseSlice(slice []string) []string {
-	newSlice := make([]string, len(slice))
+import (
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// timestampLayout is the format graph api uses for the timestamp field.
+const timestampLayout = "2006-01-02T15:04:05-0700"
+
+func reverseSlice(slice []MediaDetail) []MediaDetail {
+	newSlice := make([]MediaDetail, len(slice))
 	copy(newSlice, slice)
 
 	for i, j := 0, len(newSlice)-1; i < j; i, j = i+1, j-1 {
@@ -9,3 +18,33 @@ func reverseSlice(slice []string) []string {
 	}
 	return newSlice
 }
+
+// Time returns the parsed creation time of the media or zero time if it is
+// missing.
+func (m *MediaDetail) Time() time.Time {
+	t, err := time.Parse(timestampLayout, m.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Item converts the media detail into a source.Item.
+func (m *MediaDetail) Item() *source.Item {
+	item := &source.Item{
+		ID:           m.ID,
+		Caption:      m.Caption,
+		MediaType:    m.MediaType,
+		MediaURL:     m.MediaURL,
+		Permalink:    m.Permalink,
+		Timestamp:    m.Time(),
+		ThumbnailURL: m.ThumbnailURL,
+		Username:     m.Username,
+		Shortcode:    m.Shortcode,
+	}
+	for i := range m.Children.Data {
+		item.Children = append(item.Children, m.Children.Data[i].Item())
+	}
+
+	return item
+}
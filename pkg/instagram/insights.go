@@ -0,0 +1,68 @@
+package instagram
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+type InsightsResponse struct {
+	Data []struct {
+		Name   string `json:"name"`
+		Values []struct {
+			Value int `json:"value"`
+		} `json:"values"`
+	} `json:"data"`
+}
+
+type EngagementResponse struct {
+	LikeCount     int `json:"like_count"`
+	CommentsCount int `json:"comments_count"`
+}
+
+// insightsMetrics returns the insights metrics graph api supports for the
+// media type.
+func insightsMetrics(mediaType string) string {
+	if mediaType == source.MediaTypeVideo {
+		return "plays,reach"
+	}
+	return "impressions,reach"
+}
+
+// FetchInsights returns insights (impressions or plays, reach) and engagement
+// counters (likes, comments) of the media.
+func (c *Client) FetchInsights(ctx context.Context, id, mediaType string) (map[string]int, error) {
+	q := url.Values{}
+	q.Set("access_token", c.token)
+	q.Set("metric", insightsMetrics(mediaType))
+
+	var insights InsightsResponse
+	err := c.getJSON(ctx, fmt.Sprintf("%s/%s/insights?%s", c.api, id, q.Encode()), &insights)
+	if err != nil {
+		return nil, err
+	}
+
+	q = url.Values{}
+	q.Set("access_token", c.token)
+	q.Set("fields", "like_count,comments_count")
+
+	var engagement EngagementResponse
+	err = c.getJSON(ctx, fmt.Sprintf("%s/%s?%s", c.api, id, q.Encode()), &engagement)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := map[string]int{
+		"likes":    engagement.LikeCount,
+		"comments": engagement.CommentsCount,
+	}
+	for _, m := range insights.Data {
+		if len(m.Values) > 0 {
+			metrics[m.Name] = m.Values[0].Value
+		}
+	}
+
+	return metrics, nil
+}
@@ -0,0 +1,63 @@
+package instagram
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Source implements the source.Source interface.
+var _ source.Source = (*Source)(nil)
+
+// Source exposes one edge of the instagram account (media or stories) as a
+// source.Source.
+type Source struct {
+	client *Client
+	kind   source.Kind
+}
+
+func NewSource(client *Client, kind source.Kind) *Source {
+	return &Source{
+		client: client,
+		kind:   kind,
+	}
+}
+
+func (s *Source) Kind() source.Kind {
+	return s.kind
+}
+
+func (s *Source) List(ctx context.Context, cursor string) (*source.Page, error) {
+	media, next, err := s.client.FetchMediaPage(ctx, string(s.kind), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &source.Page{Next: next}
+	for i := range media {
+		page.Items = append(page.Items, media[i].Item())
+	}
+	source.SortItems(page.Items)
+
+	return page, nil
+}
+
+func (s *Source) Detail(ctx context.Context, id string) (*source.Item, error) {
+	media, err := s.client.FetchMediaDetail(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return media.Item(), nil
+}
+
+func (s *Source) Download(ctx context.Context, item *source.Item) (io.ReadCloser, error) {
+	if item.MediaURL == "" {
+		return nil, fmt.Errorf("No media url for id: %+v", item.ID)
+	}
+
+	return downloader.DownloadFile(item.MediaURL)
+}
@@ -0,0 +1,258 @@
+package mastodon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+)
+
+// rateLimitPause is how long requests are paused after a rate limit error
+// without a reset time.
+const rateLimitPause = 5 * time.Minute
+
+// Client is a minimal Mastodon API client of one account.
+type Client struct {
+	httpClient *http.Client
+	token      string
+	instance   string
+
+	mu sync.Mutex
+	// pausedUntil is set after rate limit errors, requests fail fast until
+	// then
+	pausedUntil time.Time
+}
+
+func NewClient(cfg config.MastodonConfig) *Client {
+	// token should be passed through env and fallback to config.yaml
+	token := os.Getenv("MASTODON_ACCESS_TOKEN")
+	if token == "" {
+		token = cfg.AccessToken
+	}
+
+	return &Client{
+		httpClient: &http.Client{},
+		token:      token,
+		instance:   strings.TrimRight(cfg.Instance, "/"),
+	}
+}
+
+// APIError is the error object the Mastodon API returns on failure.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("mastodon api error %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether the error means the requested object does not
+// exist (anymore).
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// rejected reports whether the instance refused the status or media itself,
+// e.g. a file that is too large or of an unsupported type.
+func rejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusRequestEntityTooLarge || apiErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// Attachment is an uploaded media attachment.
+type Attachment struct {
+	ID string `json:"id"`
+	// URL is empty while the instance is still processing the media
+	URL string `json:"url"`
+}
+
+// Status is the part of a status the publisher needs.
+type Status struct {
+	ID               string       `json:"id"`
+	SpoilerText      string       `json:"spoiler_text"`
+	Sensitive        bool         `json:"sensitive"`
+	MediaAttachments []Attachment `json:"media_attachments"`
+}
+
+// Upload is a file uploaded as a media attachment, Open returns its content.
+type Upload struct {
+	Name        string
+	ContentType string
+	Open        func() (io.ReadCloser, error)
+	// Description is the alt text of the media
+	Description string
+}
+
+// Paused returns a non-nil error while requests are paused after a rate
+// limit error.
+func (c *Client) Paused() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.pausedUntil) {
+		return fmt.Errorf("mastodon requests are paused until %s after a rate limit error", c.pausedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// UploadMedia uploads the file through the v2 media API. Large media is
+// processed asynchronously, the returned attachment has no URL until then.
+func (c *Client) UploadMedia(ctx context.Context, upload Upload) (*Attachment, error) {
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUpload(w, upload))
+	}()
+
+	var attachment Attachment
+	err := c.do(ctx, http.MethodPost, "/api/v2/media", w.FormDataContentType(), pr, "", &attachment)
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// Media returns the attachment, e.g. to check if it is processed.
+func (c *Client) Media(ctx context.Context, id string) (*Attachment, error) {
+	var attachment Attachment
+	if err := c.do(ctx, http.MethodGet, "/api/v1/media/"+id, "", nil, "", &attachment); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// PostStatus creates a status. The idempotency key keeps retries of the same
+// request from posting twice.
+func (c *Client) PostStatus(ctx context.Context, params map[string]interface{}, idempotencyKey string) (*Status, error) {
+	var status Status
+	if err := c.json(ctx, http.MethodPost, "/api/v1/statuses", params, idempotencyKey, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetStatus returns the status.
+func (c *Client) GetStatus(ctx context.Context, id string) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/api/v1/statuses/"+id, "", nil, "", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// EditStatus replaces the status. Fields left out are reset, e.g. media
+// without media_ids is removed.
+func (c *Client) EditStatus(ctx context.Context, id string, params map[string]interface{}) error {
+	return c.json(ctx, http.MethodPut, "/api/v1/statuses/"+id, params, "", nil)
+}
+
+// DeleteStatus deletes the status.
+func (c *Client) DeleteStatus(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/statuses/"+id, "", nil, "", nil)
+}
+
+func (c *Client) json(ctx context.Context, method, path string, params map[string]interface{}, idempotencyKey string, v interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, "application/json", bytes.NewReader(data), idempotencyKey, v)
+}
+
+// do performs the request and decodes the response into v.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, idempotencyKey string, v interface{}) error {
+	if err := c.Paused(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.instance+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		json.Unmarshal(data, apiErr)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.pause(resp.Header.Get("X-RateLimit-Reset"))
+		}
+		return apiErr
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// pause stops requests until the rate limit resets.
+func (c *Client) pause(reset string) {
+	until, err := time.Parse(time.RFC3339, reset)
+	if err != nil {
+		until = time.Now().Add(rateLimitPause)
+	}
+
+	c.mu.Lock()
+	c.pausedUntil = until
+	c.mu.Unlock()
+}
+
+// writeUpload streams the upload as a multipart form, so videos are not
+// buffered in memory.
+func writeUpload(w *multipart.Writer, upload Upload) error {
+	if upload.Description != "" {
+		if err := w.WriteField("description", upload.Description); err != nil {
+			return err
+		}
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%s`, strconv.Quote(upload.Name)))
+	header.Set("Content-Type", upload.ContentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	body, err := upload.Open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(part, body); err != nil {
+		return err
+	}
+	return w.Close()
+}
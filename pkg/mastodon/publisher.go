@@ -0,0 +1,232 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Publisher implements the publish.Publisher interface.
+var _ publish.Publisher = (*Publisher)(nil)
+
+// Destination is the name of the Mastodon publisher in sync profiles.
+const Destination = "mastodon"
+
+const (
+	// maxAttachments is the media limit of statuses
+	maxAttachments = 4
+	// maxDescription is the alt text limit of media attachments
+	maxDescription = 1500
+	// processingPoll is how often processing of uploaded media is checked
+	processingPoll = 2 * time.Second
+)
+
+// Visibilities of statuses.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+	VisibilityDirect   = "direct"
+)
+
+// Publisher publishes items as statuses of a Mastodon account. The status id
+// is the ref of a delivered item.
+type Publisher struct {
+	client *Client
+	cfg    config.MastodonConfig
+	// processingTimeout bounds the wait for the instance to process media
+	processingTimeout time.Duration
+}
+
+func NewPublisher(client *Client, cfg config.MastodonConfig) (*Publisher, error) {
+	// set default fallback values
+	if cfg.MaxCharacters == 0 {
+		cfg.MaxCharacters = 500
+	}
+	if cfg.ProcessingTimeout == 0 {
+		cfg.ProcessingTimeout = 300
+	}
+
+	switch cfg.Visibility {
+	case "":
+		cfg.Visibility = VisibilityPublic
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate, VisibilityDirect:
+	default:
+		return nil, fmt.Errorf("unknown mastodon visibility: %s", cfg.Visibility)
+	}
+
+	return &Publisher{
+		client:            client,
+		cfg:               cfg,
+		processingTimeout: time.Duration(cfg.ProcessingTimeout) * time.Second,
+	}, nil
+}
+
+func (p *Publisher) Name() string {
+	return Destination
+}
+
+func (p *Publisher) Capabilities() publish.Capabilities {
+	return publish.Capabilities{
+		Text:           true,
+		Photos:         true,
+		Videos:         true,
+		MaxAttachments: maxAttachments,
+		MaxCaption:     p.cfg.MaxCharacters,
+		Edit:           true,
+		Delete:         true,
+	}
+}
+
+// Paused returns why Mastodon requests are paused or nil.
+func (p *Publisher) Paused() error {
+	return p.client.Paused()
+}
+
+func (p *Publisher) Post(ctx context.Context, post *publish.Post) (*publish.Published, error) {
+	mediaIDs := make([]string, 0, len(post.Media))
+	for i, media := range post.Media {
+		attachment, err := p.upload(ctx, media, post.Caption, i)
+		if rejected(err) {
+			return nil, publish.Rejected(err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mediaIDs = append(mediaIDs, attachment.ID)
+	}
+
+	params := p.statusParams(post.Caption, mediaIDs)
+	params["visibility"] = p.cfg.Visibility
+
+	// the same item is never posted twice, even if saving the delivery fails
+	var idempotencyKey string
+	if post.Item != nil {
+		idempotencyKey = "inst2vk-" + post.Item.ID
+	}
+
+	status, err := p.client.PostStatus(ctx, params, idempotencyKey)
+	if rejected(err) {
+		return nil, publish.Rejected(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &publish.Published{Ref: publish.Ref(status.ID)}, nil
+}
+
+// upload uploads the staged media with the caption as alt text and waits
+// until the instance processed it, statuses can't attach unprocessed media.
+func (p *Publisher) upload(ctx context.Context, media publish.Media, caption string, i int) (*Attachment, error) {
+	contentType := media.ContentType
+	if contentType == "" {
+		contentType = "image/jpeg"
+		if media.Type == source.MediaTypeVideo {
+			contentType = "video/mp4"
+		}
+	}
+
+	upload := Upload{
+		Name:        fmt.Sprintf("media%d%s", i, extension(contentType)),
+		ContentType: contentType,
+		Description: publish.Truncate(caption, maxDescription),
+		Open: func() (io.ReadCloser, error) {
+			return downloader.DownloadFile(media.URL)
+		},
+	}
+
+	attachment, err := p.client.UploadMedia(ctx, upload)
+	if err != nil {
+		return nil, fmt.Errorf("upload mastodon media: %w", err)
+	}
+
+	deadline := time.Now().Add(p.processingTimeout)
+	for attachment.URL == "" {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("mastodon media %s was not processed in %s", attachment.ID, p.processingTimeout)
+		}
+
+		select {
+		case <-time.After(processingPoll):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		attachment, err = p.client.Media(ctx, attachment.ID)
+		if err != nil {
+			return nil, fmt.Errorf("check mastodon media: %w", err)
+		}
+	}
+
+	return attachment, nil
+}
+
+// extension returns the file extension of the MIME type, instances check
+// uploads against both.
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "video/mp4":
+		return ".mp4"
+	case "video/quicktime":
+		return ".mov"
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+func (p *Publisher) Story(ctx context.Context, story *publish.Story) (*publish.Published, error) {
+	return nil, fmt.Errorf("mastodon stories: %w", publish.ErrNotSupported)
+}
+
+// Edit replaces the text of the status. Edits replace the whole status, so
+// its media and content warning are sent again.
+func (p *Publisher) Edit(ctx context.Context, ref publish.Ref, caption string) error {
+	status, err := p.client.GetStatus(ctx, string(ref))
+	if err != nil {
+		return err
+	}
+
+	mediaIDs := make([]string, 0, len(status.MediaAttachments))
+	for _, attachment := range status.MediaAttachments {
+		mediaIDs = append(mediaIDs, attachment.ID)
+	}
+
+	params := p.statusParams(caption, mediaIDs)
+	params["spoiler_text"] = status.SpoilerText
+	params["sensitive"] = status.Sensitive
+	return p.client.EditStatus(ctx, status.ID, params)
+}
+
+func (p *Publisher) Delete(ctx context.Context, ref publish.Ref) error {
+	err := p.client.DeleteStatus(ctx, string(ref))
+	if IsNotFound(err) {
+		log.Printf("[publisher:mastodon] Status %s is already deleted\n", ref)
+		return nil
+	}
+	return err
+}
+
+// statusParams returns the parameters of a new or edited status.
+func (p *Publisher) statusParams(caption string, mediaIDs []string) map[string]interface{} {
+	params := map[string]interface{}{
+		"status":    publish.Truncate(caption, p.cfg.MaxCharacters),
+		"media_ids": mediaIDs,
+		"sensitive": p.cfg.Sensitive,
+	}
+	if p.cfg.SpoilerText != "" {
+		params["spoiler_text"] = p.cfg.SpoilerText
+	}
+	return params
+}
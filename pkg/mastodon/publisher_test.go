@@ -0,0 +1,183 @@
+package mastodon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// instance is a stub Mastodon instance recording the requests it got.
+type instance struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	uploads  []string
+	polls    int
+	statuses []map[string]interface{}
+	edits    []map[string]interface{}
+	deleted  []string
+	keys     []string
+}
+
+func (s *instance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the staged media, downloaded while the upload request is still read
+	if r.URL.Path == "/media/photo.png" {
+		w.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		s.t.Errorf("%s %s: Authorization = %q", r.Method, r.URL.Path, got)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/media":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			s.t.Errorf("upload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		s.uploads = append(s.uploads, header.Filename+" "+header.Header.Get("Content-Type")+" "+r.FormValue("description")+" "+string(data))
+
+		// processed asynchronously, the url is set on the first poll
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(Attachment{ID: "m1"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/media/m1":
+		s.polls++
+		json.NewEncoder(w).Encode(Attachment{ID: "m1", URL: "https://files.example/m1.png"})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/statuses":
+		s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		s.statuses = append(s.statuses, params)
+		json.NewEncoder(w).Encode(Status{ID: "s1"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/statuses/s1":
+		json.NewEncoder(w).Encode(Status{
+			ID:               "s1",
+			SpoilerText:      "cw",
+			MediaAttachments: []Attachment{{ID: "m1", URL: "https://files.example/m1.png"}},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/statuses/s1":
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		s.edits = append(s.edits, params)
+		json.NewEncoder(w).Encode(Status{ID: "s1"})
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/statuses/s1":
+		s.deleted = append(s.deleted, "s1")
+		json.NewEncoder(w).Encode(Status{ID: "s1"})
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/statuses/gone":
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Record not found"}`))
+	default:
+		s.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	t.Setenv("MASTODON_ACCESS_TOKEN", "")
+	stub := &instance{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	cfg := config.MastodonConfig{Instance: server.URL + "/", AccessToken: "token", MaxCharacters: 10}
+	p, err := NewPublisher(NewClient(cfg), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	published, err := p.Post(ctx, &publish.Post{
+		Item:    &source.Item{ID: "42"},
+		Caption: "a caption longer than ten",
+		Media: []publish.Media{{
+			Type:        source.MediaTypeImage,
+			URL:         server.URL + "/media/photo.png",
+			ContentType: "image/png",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if published.Ref != "s1" {
+		t.Errorf("Ref = %q, want s1", published.Ref)
+	}
+
+	if want := []string{"media0.png image/png a caption longer than ten \x89PNG\r\n\x1a\nimage"}; len(stub.uploads) != 1 || stub.uploads[0] != want[0] {
+		t.Errorf("uploads = %q, want %q", stub.uploads, want)
+	}
+	if stub.polls != 1 {
+		t.Errorf("polls = %d, want 1", stub.polls)
+	}
+	if len(stub.statuses) != 1 {
+		t.Fatalf("statuses = %v, want one", stub.statuses)
+	}
+	status := stub.statuses[0]
+	if status["status"] != "a caption…" || status["visibility"] != VisibilityPublic {
+		t.Errorf("status = %v", status)
+	}
+	if ids, _ := status["media_ids"].([]interface{}); len(ids) != 1 || ids[0] != "m1" {
+		t.Errorf("media_ids = %v, want [m1]", status["media_ids"])
+	}
+	if stub.keys[0] != "inst2vk-42" {
+		t.Errorf("Idempotency-Key = %q, want inst2vk-42", stub.keys[0])
+	}
+
+	if err := p.Edit(ctx, published.Ref, "new"); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	if len(stub.edits) != 1 {
+		t.Fatalf("edits = %v, want one", stub.edits)
+	}
+	edit := stub.edits[0]
+	if edit["status"] != "new" || edit["spoiler_text"] != "cw" {
+		t.Errorf("edit = %v", edit)
+	}
+	if ids, _ := edit["media_ids"].([]interface{}); len(ids) != 1 || ids[0] != "m1" {
+		t.Errorf("edit media_ids = %v, want [m1]", edit["media_ids"])
+	}
+
+	if err := p.Delete(ctx, published.Ref); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := p.Delete(ctx, "gone"); err != nil {
+		t.Errorf("Delete of a deleted status: %v", err)
+	}
+	if len(stub.deleted) != 1 {
+		t.Errorf("deleted = %v, want [s1]", stub.deleted)
+	}
+}
+
+func TestRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error":"Validation failed: File content type is invalid"}`))
+	}))
+	defer server.Close()
+
+	cfg := config.MastodonConfig{Instance: server.URL, AccessToken: "token"}
+	p, err := NewPublisher(NewClient(cfg), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Post(context.Background(), &publish.Post{Caption: "text"})
+	if !errors.Is(err, publish.ErrRejected) {
+		t.Errorf("Post error = %v, want a rejection", err)
+	}
+}
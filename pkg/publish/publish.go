@@ -0,0 +1,184 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// ErrNotSupported is returned for actions the publisher doesn't support.
+var ErrNotSupported = errors.New("not supported by the publisher")
+
+// ErrRejected marks errors of items the destination will never take, e.g. a
+// file that is too large. Such items are not retried for the destination.
+var ErrRejected = errors.New("rejected by the destination")
+
+// Rejected wraps err into ErrRejected.
+func Rejected(err error) error {
+	return fmt.Errorf("%w: %w", ErrRejected, err)
+}
+
+// ProcessingError is returned when the destination took the upload but is
+// still processing it. Ref identifies the upload, it is passed back as
+// Post.Processing on the next attempt, which checks it instead of uploading
+// again.
+type ProcessingError struct {
+	Ref Ref
+	Err error
+}
+
+func (e *ProcessingError) Error() string {
+	return fmt.Sprintf("still processing %s: %v", e.Ref, e.Err)
+}
+
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
+
+// Capabilities describes what a publisher supports. Items a publisher can't
+// take are skipped for it.
+type Capabilities struct {
+	Text   bool
+	Photos bool
+	Videos bool
+	// MaxAttachments per post, 0 means no limit
+	MaxAttachments int
+	// MaxCaption in characters, 0 means no limit. Longer captions are cut
+	// by the publisher
+	MaxCaption int
+	// MaxText is the limit of text only posts if it differs from
+	// MaxCaption, e.g. Telegram allows longer text messages than captions
+	MaxText int
+	// Schedule is set if posts can be postponed, otherwise they are
+	// published right away
+	Schedule bool
+	Stories  bool
+	Edit     bool
+	Delete   bool
+}
+
+// Media is a photo or video attached to a post or story.
+type Media struct {
+	// Type is source.MediaTypeImage or source.MediaTypeVideo
+	Type string
+	// URL is where the media is staged, publishers download it from there
+	URL string
+	// ContentType is the MIME type of the staged media, e.g. image/png
+	ContentType  string
+	ThumbnailURL string
+}
+
+// Post is an item published as a post.
+type Post struct {
+	// Item is the original item, e.g. for placement rules
+	Item    *source.Item
+	Caption string
+	// Media is empty for text posts
+	Media []Media
+	// PublishAt postpones the post, zero publishes right away
+	PublishAt time.Time
+	// Processing is the ref of a ProcessingError of an earlier attempt and
+	// ProcessingSince when that upload was first reported processing
+	Processing      Ref
+	ProcessingSince time.Time
+}
+
+// Story is an item published as a story.
+type Story struct {
+	Item  *source.Item
+	Media Media
+	// LinkText and LinkURL describe the link button, no button when LinkURL
+	// is empty
+	LinkText string
+	LinkURL  string
+	// ReplyToStory and UserIDs are the audience from the sync profile of
+	// the story, publishers without such options ignore them
+	ReplyToStory string
+	UserIDs      []int
+}
+
+// Ref identifies a published object at its destination. The format is up to
+// the publisher.
+type Ref string
+
+// Published describes an object created by a publisher.
+type Published struct {
+	Ref Ref
+	// ExpiresAt is set for stories
+	ExpiresAt time.Time
+}
+
+// Publisher interface defines the contract for a destination items are
+// published to.
+type Publisher interface {
+	// Name identifies the destination in sync profiles and in the sync
+	// state.
+	Name() string
+	Capabilities() Capabilities
+	Post(ctx context.Context, post *Post) (*Published, error)
+	Story(ctx context.Context, story *Story) (*Published, error)
+	// Edit replaces the caption of the published object.
+	Edit(ctx context.Context, ref Ref, caption string) error
+	Delete(ctx context.Context, ref Ref) error
+}
+
+// Pauser is implemented by publishers that stop accepting requests for a
+// while, e.g. after hitting rate limits.
+type Pauser interface {
+	// Paused returns a non-nil error while the publisher is paused.
+	Paused() error
+}
+
+// Paused returns why the publisher is paused or nil.
+func Paused(p Publisher) error {
+	if pauser, ok := p.(Pauser); ok {
+		return pauser.Paused()
+	}
+	return nil
+}
+
+// Supports reports whether the publisher can take a post with the media.
+func (c Capabilities) Supports(media []Media) bool {
+	if len(media) == 0 {
+		return c.Text
+	}
+	for _, m := range media {
+		switch m.Type {
+		case source.MediaTypeImage:
+			if !c.Photos {
+				return false
+			}
+		case source.MediaTypeVideo:
+			if !c.Videos {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// CaptionLimit returns the caption limit of a post with the media, 0 means
+// no limit.
+func (c Capabilities) CaptionLimit(media []Media) int {
+	if len(media) == 0 && c.MaxText > 0 {
+		return c.MaxText
+	}
+	return c.MaxCaption
+}
+
+// Truncate cuts the caption to max characters, 0 means no limit.
+func Truncate(caption string, max int) string {
+	runes := []rune(caption)
+	if max <= 0 || len(runes) <= max {
+		return caption
+	}
+	if max == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,101 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+)
+
+// immediate is how close to now a slot has to be to publish right away
+// instead of postponing the post.
+const immediate = time.Minute
+
+// Rules decide when posts of a destination are published: only within the
+// posting hours, with a minimum gap between posts and a daily limit.
+type Rules struct {
+	loc       *time.Location
+	startHour int
+	endHour   int
+	minGap    time.Duration
+	maxPerDay int
+}
+
+func NewRules(config config.ScheduleConfig) (*Rules, error) {
+	loc := time.Local
+	if config.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// set default fallback values
+	if config.EndHour == 0 {
+		config.EndHour = 24
+	}
+
+	// windows don't wrap around midnight, Next would find no slot at all
+	if config.StartHour < 0 || config.EndHour > 24 || config.StartHour >= config.EndHour {
+		return nil, fmt.Errorf("invalid posting hours %d-%d, want 0 <= start_hour < end_hour <= 24", config.StartHour, config.EndHour)
+	}
+	if config.MinGap < 0 || config.MaxPerDay < 0 {
+		return nil, fmt.Errorf("min_gap and max_per_day must not be negative")
+	}
+
+	return &Rules{
+		loc:       loc,
+		startHour: config.StartHour,
+		endHour:   config.EndHour,
+		minGap:    time.Duration(config.MinGap) * time.Second,
+		maxPerDay: config.MaxPerDay,
+	}, nil
+}
+
+// Next returns the earliest slot after now that satisfies the rules given the
+// publish times already taken. A zero time means publish right away.
+func (r *Rules) Next(now time.Time, taken []time.Time) time.Time {
+	sort.Slice(taken, func(i, j int) bool { return taken[i].Before(taken[j]) })
+
+	slot := now.In(r.loc)
+	if len(taken) > 0 && slot.Before(taken[len(taken)-1].Add(r.minGap)) {
+		slot = taken[len(taken)-1].Add(r.minGap).In(r.loc)
+	}
+
+	// a year of days is plenty to find a slot with sane rules
+	for i := 0; i < 366; i++ {
+		dayStart := time.Date(slot.Year(), slot.Month(), slot.Day(), 0, 0, 0, 0, r.loc)
+		// wall clock hours, days with a DST switch are not 24 hours long
+		windowStart := time.Date(slot.Year(), slot.Month(), slot.Day(), r.startHour, 0, 0, 0, r.loc)
+		windowEnd := time.Date(slot.Year(), slot.Month(), slot.Day(), r.endHour, 0, 0, 0, r.loc)
+
+		if slot.Before(windowStart) {
+			slot = windowStart
+		}
+		if !slot.Before(windowEnd) || (r.maxPerDay > 0 && countDay(taken, dayStart) >= r.maxPerDay) {
+			slot = dayStart.AddDate(0, 0, 1)
+			continue
+		}
+
+		if slot.Sub(now) < immediate {
+			return time.Time{}
+		}
+		return slot
+	}
+
+	return time.Time{}
+}
+
+// countDay returns how many of the times fall on the day starting at dayStart.
+func countDay(times []time.Time, dayStart time.Time) int {
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	var count int
+	for _, t := range times {
+		if !t.Before(dayStart) && t.Before(dayEnd) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+)
+
+func TestNext(t *testing.T) {
+	rules, err := NewRules(config.ScheduleConfig{
+		Timezone:  "Europe/Berlin",
+		StartHour: 9,
+		EndHour:   22,
+		MinGap:    3600,
+		MaxPerDay: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, time.March, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		taken []time.Time
+		want  time.Time
+	}{
+		{"within the window", at(10, 12, 0), nil, time.Time{}},
+		{"before the window", at(10, 7, 0), nil, at(10, 9, 0)},
+		{"after the window", at(10, 23, 0), nil, at(11, 9, 0)},
+		{"gap to the last post", at(10, 12, 0), []time.Time{at(10, 11, 30)}, at(10, 12, 30)},
+		{"gap past the window end", at(10, 21, 30), []time.Time{at(10, 21, 0)}, at(11, 9, 0)},
+		{"day limit", at(10, 12, 0), []time.Time{at(10, 9, 0), at(10, 10, 0)}, at(11, 9, 0)},
+		{"taken days before don't count", at(10, 12, 0), []time.Time{at(9, 9, 0), at(9, 10, 0)}, time.Time{}},
+		// clocks jump from 02:00 to 03:00, the window still opens at 09:00
+		{"dst switch", at(29, 5, 0), nil, at(29, 9, 0)},
+		{"dst switch after the window", at(28, 23, 0), nil, at(29, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules.Next(tt.now, tt.taken)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRulesInvalidHours(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+	}{
+		{"over midnight", 22, 9},
+		{"empty window", 9, 9},
+		{"negative start", -1, 10},
+		{"end after midnight", 9, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRules(config.ScheduleConfig{StartHour: tt.start, EndHour: tt.end})
+			if err == nil {
+				t.Errorf("NewRules(%d-%d) succeeded, want an error", tt.start, tt.end)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ type UploadVideoRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	FileURL     string `json:"file_url"` // updated
+	vk.VideoOverrides
 }
 
 func NewServer(videoService *vk.VideoService) *http.ServeMux {
@@ -18,5 +19,8 @@ func NewServer(videoService *vk.VideoService) *http.ServeMux {
 	uploadHandler := http.HandlerFunc(videoService.UploadHandler)
 	mux.Handle("/upload", AuthMiddleware(LoggingMiddleware(uploadHandler)))
 
+	healthHandler := http.HandlerFunc(videoService.HealthHandler)
+	mux.Handle("/health", AuthMiddleware(LoggingMiddleware(healthHandler)))
+
 	return mux
 }
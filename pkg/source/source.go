@@ -0,0 +1,139 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind describes what a source produces. It matches the sync table the items
+// are tracked in.
+type Kind string
+
+const (
+	KindMedia   Kind = "media"
+	KindStories Kind = "stories"
+	KindTags    Kind = "tags"
+	KindFolder  Kind = "folder"
+	KindFeed    Kind = "feed"
+	KindArchive Kind = "archive"
+)
+
+// Media types of items. They follow instagram media types, TEXT is used by
+// sources whose items may carry no media at all.
+const (
+	MediaTypeImage    = "IMAGE"
+	MediaTypeVideo    = "VIDEO"
+	MediaTypeCarousel = "CAROUSEL_ALBUM"
+	MediaTypeText     = "TEXT"
+)
+
+// mediaExts maps supported media file extensions to media types. Only
+// formats VK accepts for uploads are listed, e.g. no webp or heic.
+var mediaExts = map[string]string{
+	".jpg":  MediaTypeImage,
+	".jpeg": MediaTypeImage,
+	".png":  MediaTypeImage,
+	".gif":  MediaTypeImage,
+	".mp4":  MediaTypeVideo,
+	".mov":  MediaTypeVideo,
+}
+
+// contentTypes maps supported media file extensions to MIME types.
+var contentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+}
+
+// SniffContentType detects the MIME type of the media from its first bytes
+// and returns a reader that still yields the whole content. Media Go doesn't
+// recognize, e.g. QuickTime videos, falls back to the file extension of the
+// item id or media url, then to jpeg or mp4 by media type.
+func SniffContentType(r io.Reader, item *Item) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	r = io.MultiReader(bytes.NewReader(head), r)
+
+	contentType := http.DetectContentType(head)
+	if strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/") {
+		return contentType, r, nil
+	}
+
+	ext := filepath.Ext(item.ID)
+	if u, err := url.Parse(item.MediaURL); ext == "" && err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if contentType, ok := contentTypes[strings.ToLower(ext)]; ok {
+		return contentType, r, nil
+	}
+	if item.MediaType == MediaTypeVideo {
+		return "video/mp4", r, nil
+	}
+	return "image/jpeg", r, nil
+}
+
+// MediaTypeOf returns the media type of the file by its extension or an
+// empty string if the file is not a supported media file.
+func MediaTypeOf(path string) string {
+	return mediaExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// Item is a single piece of content returned by a Source.
+type Item struct {
+	ID           string
+	Caption      string
+	MediaType    string
+	MediaURL     string
+	Permalink    string
+	Timestamp    time.Time
+	ThumbnailURL string
+	Username     string
+	Shortcode    string
+	// Children holds the parts of a carousel.
+	Children []*Item
+}
+
+// Page is one page of items with full details. Next is the cursor of the
+// following (older) page and is empty when there is nothing left to list.
+type Page struct {
+	Items []*Item
+	Next  string
+}
+
+// Source interface defines the contract for anything that can feed items into
+// the sync pipeline.
+type Source interface {
+	// Kind reports which kind of items the source produces.
+	Kind() Kind
+	// List returns a page of items starting at cursor, ordered by creation
+	// time from the oldest to the newest.
+	// An empty cursor starts from the most recent items.
+	List(ctx context.Context, cursor string) (*Page, error)
+	// Detail returns the item with the given id.
+	Detail(ctx context.Context, id string) (*Item, error)
+	// Download opens the content of the item. The caller must close it.
+	Download(ctx context.Context, item *Item) (io.ReadCloser, error)
+}
+
+// SortItems orders items by creation time from the oldest to the newest. Items
+// without a timestamp keep their relative position.
+func SortItems(items []*Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Timestamp.Before(items[j].Timestamp)
+	})
+}
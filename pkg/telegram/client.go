@@ -0,0 +1,230 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+)
+
+// Client is a minimal Bot API client posting to one chat.
+type Client struct {
+	httpClient *http.Client
+	token      string
+	api        string
+	chatID     string
+	silent     bool
+
+	mu sync.Mutex
+	// pausedUntil is set after flood errors, requests fail fast until then
+	pausedUntil time.Time
+}
+
+func NewClient(cfg config.TelegramConfig) *Client {
+	// token should be passed through env and fallback to config.yaml
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		token = cfg.BotToken
+	}
+
+	// set default fallback values
+	if cfg.API == "" {
+		cfg.API = "https://api.telegram.org"
+	}
+
+	return &Client{
+		httpClient: &http.Client{},
+		token:      token,
+		api:        strings.TrimRight(cfg.API, "/"),
+		chatID:     cfg.ChatID,
+		silent:     cfg.DisableNotification,
+	}
+}
+
+// APIError is the error object the Bot API returns instead of a result.
+type APIError struct {
+	Code        int    `json:"error_code"`
+	Description string `json:"description"`
+	// RetryAfter is set for flood errors (429), in seconds
+	RetryAfter int `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram bot api error %d: %s", e.Code, e.Description)
+}
+
+// rejected reports whether the Bot API refused the message itself, e.g. a
+// file that is too large or of a wrong type. Errors about the chat, like a
+// missing chat or missing rights, are not the fault of the message.
+func rejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusRequestEntityTooLarge:
+		return true
+	case http.StatusBadRequest:
+		return !strings.Contains(strings.ToLower(apiErr.Description), "chat")
+	}
+	return false
+}
+
+// response is the envelope of every Bot API response.
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Message is the part of a sent message the publisher needs.
+type Message struct {
+	MessageID int `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// file is a file uploaded with a request, opened only once the request body
+// is written.
+type file struct {
+	field string
+	name  string
+	open  func() (io.ReadCloser, error)
+}
+
+// Paused returns a non-nil error while requests are paused after a flood
+// error.
+func (c *Client) Paused() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.pausedUntil) {
+		return fmt.Errorf("telegram requests are paused until %s after a flood error", c.pausedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// call invokes the Bot API method and decodes its result into v. Parameters
+// are sent as JSON, or as multipart form fields when files are uploaded.
+func (c *Client) call(ctx context.Context, method string, params map[string]interface{}, files []file, v interface{}) error {
+	if err := c.Paused(); err != nil {
+		return err
+	}
+
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if len(files) == 0 {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	} else {
+		var err error
+		body, contentType, err = multipartBody(params, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	reqURL := fmt.Sprintf("%s/bot%s/%s", c.api, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// the url carries the token, keep it out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("telegram %s: %s: %w", method, resp.Status, err)
+	}
+	if !r.OK {
+		apiErr := &APIError{Code: r.ErrorCode, Description: r.Description, RetryAfter: r.Parameters.RetryAfter}
+		if apiErr.RetryAfter > 0 {
+			c.mu.Lock()
+			c.pausedUntil = time.Now().Add(time.Duration(apiErr.RetryAfter) * time.Second)
+			c.mu.Unlock()
+		}
+		return apiErr
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, v)
+}
+
+// multipartBody streams the parameters and files as a multipart form, so
+// videos are not buffered in memory.
+func multipartBody(params map[string]interface{}, files []file) (io.Reader, string, error) {
+	fields := make(map[string]string, len(params))
+	for key, value := range params {
+		if s, ok := value.(string); ok {
+			fields[key] = s
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", err
+		}
+		fields[key] = string(data)
+	}
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(w, fields, files))
+	}()
+	return pr, w.FormDataContentType(), nil
+}
+
+func writeMultipart(w *multipart.Writer, fields map[string]string, files []file) error {
+	for key, value := range fields {
+		if err := w.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		part, err := w.CreateFormFile(f.field, f.name)
+		if err != nil {
+			return err
+		}
+		body, err := f.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Message limits in UTF-16 code units, the way the Bot API counts them.
+const (
+	maxText    = 4096
+	maxCaption = 1024
+	// maxMediaGroup is the most photos and videos of one media group
+	maxMediaGroup = 10
+)
+
+// Media types of sendMediaGroup items.
+const (
+	MediaPhoto = "photo"
+	MediaVideo = "video"
+)
+
+// InputMedia is a photo or video to send, Open returns its content.
+type InputMedia struct {
+	Type string
+	Open func() (io.ReadCloser, error)
+}
+
+// SendText sends a text message. Text is sent as is, without markup.
+func (c *Client) SendText(ctx context.Context, text string) (*Message, error) {
+	params := c.params()
+	params["text"] = truncate(text, maxText)
+
+	var msg Message
+	if err := c.call(ctx, "sendMessage", params, nil, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SendMedia sends a single photo or video with the caption.
+func (c *Client) SendMedia(ctx context.Context, media InputMedia, caption string) (*Message, error) {
+	params := c.params()
+	if caption != "" {
+		params["caption"] = truncate(caption, maxCaption)
+	}
+
+	method := "sendPhoto"
+	if media.Type == MediaVideo {
+		method = "sendVideo"
+		params["supports_streaming"] = true
+	}
+
+	files := []file{{field: media.Type, name: media.Type, open: media.Open}}
+	var msg Message
+	if err := c.call(ctx, method, params, files, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SendMediaGroup sends 2 to 10 photos and videos as an album, the caption is
+// shown under the album.
+func (c *Client) SendMediaGroup(ctx context.Context, media []InputMedia, caption string) ([]Message, error) {
+	if len(media) < 2 || len(media) > maxMediaGroup {
+		return nil, fmt.Errorf("telegram media group of %d items, want 2 to %d", len(media), maxMediaGroup)
+	}
+
+	items := make([]map[string]interface{}, 0, len(media))
+	files := make([]file, 0, len(media))
+	for i, m := range media {
+		name := fmt.Sprintf("file%d", i)
+		item := map[string]interface{}{
+			"type":  m.Type,
+			"media": "attach://" + name,
+		}
+		if m.Type == MediaVideo {
+			item["supports_streaming"] = true
+		}
+		// the caption of the first item is the caption of the album
+		if i == 0 && caption != "" {
+			item["caption"] = truncate(caption, maxCaption)
+		}
+		items = append(items, item)
+		files = append(files, file{field: name, name: name, open: m.Open})
+	}
+
+	params := c.params()
+	params["media"] = items
+
+	var msgs []Message
+	if err := c.call(ctx, "sendMediaGroup", params, files, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// EditText replaces the text of a text message.
+func (c *Client) EditText(ctx context.Context, chatID int64, messageID int, text string) error {
+	params := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       truncate(text, maxText),
+	}
+	return notModified(c.call(ctx, "editMessageText", params, nil, nil))
+}
+
+// EditCaption replaces the caption of a photo or video message.
+func (c *Client) EditCaption(ctx context.Context, chatID int64, messageID int, caption string) error {
+	params := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"caption":    truncate(caption, maxCaption),
+	}
+	return notModified(c.call(ctx, "editMessageCaption", params, nil, nil))
+}
+
+// DeleteMessage deletes the message, messages that are already gone are not
+// an error.
+func (c *Client) DeleteMessage(ctx context.Context, chatID int64, messageID int) error {
+	params := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+	}
+	err := c.call(ctx, "deleteMessage", params, nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message to delete not found") {
+		return nil
+	}
+	return err
+}
+
+// params returns the parameters shared by all send methods.
+func (c *Client) params() map[string]interface{} {
+	params := map[string]interface{}{"chat_id": c.chatID}
+	if c.silent {
+		params["disable_notification"] = true
+	}
+	return params
+}
+
+// notModified drops the error the Bot API returns for edits that don't
+// change anything.
+func notModified(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message is not modified") {
+		return nil
+	}
+	return err
+}
+
+// truncate cuts the text to max UTF-16 code units.
+func truncate(text string, max int) string {
+	if len(utf16.Encode([]rune(text))) <= max {
+		return text
+	}
+
+	// leave room for the ellipsis
+	units := 0
+	for i, r := range text {
+		n := 1
+		if r >= 0x10000 {
+			n = 2
+		}
+		if units+n > max-1 {
+			return text[:i] + "…"
+		}
+		units += n
+	}
+	return text
+}
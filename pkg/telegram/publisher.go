@@ -0,0 +1,181 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Publisher implements the publish.Publisher interface.
+var _ publish.Publisher = (*Publisher)(nil)
+
+// Destination is the name of the Telegram publisher in sync profiles.
+const Destination = "telegram"
+
+// Kinds of published messages, text messages and media differ in how they
+// are edited.
+const (
+	refText  = "text"
+	refMedia = "media"
+)
+
+// Publisher publishes items to a Telegram channel or chat. Carousels are sent
+// as media groups.
+type Publisher struct {
+	client *Client
+}
+
+func NewPublisher(client *Client) *Publisher {
+	return &Publisher{client: client}
+}
+
+func (p *Publisher) Name() string {
+	return Destination
+}
+
+func (p *Publisher) Capabilities() publish.Capabilities {
+	return publish.Capabilities{
+		Text:           true,
+		Photos:         true,
+		Videos:         true,
+		MaxAttachments: maxMediaGroup,
+		MaxCaption:     maxCaption,
+		MaxText:        maxText,
+		Edit:           true,
+		Delete:         true,
+	}
+}
+
+// Paused returns why Telegram requests are paused or nil.
+func (p *Publisher) Paused() error {
+	return p.client.Paused()
+}
+
+func (p *Publisher) Post(ctx context.Context, post *publish.Post) (*publish.Published, error) {
+	media := make([]InputMedia, 0, len(post.Media))
+	for _, m := range post.Media {
+		media = append(media, inputMedia(m))
+	}
+
+	var (
+		msgs []Message
+		kind = refMedia
+		err  error
+	)
+	switch len(media) {
+	case 0:
+		var msg *Message
+		if msg, err = p.client.SendText(ctx, post.Caption); err == nil {
+			msgs, kind = []Message{*msg}, refText
+		}
+	case 1:
+		var msg *Message
+		if msg, err = p.client.SendMedia(ctx, media[0], post.Caption); err == nil {
+			msgs = []Message{*msg}
+		}
+	default:
+		msgs, err = p.client.SendMediaGroup(ctx, media, post.Caption)
+	}
+	if rejected(err) {
+		return nil, publish.Rejected(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("telegram returned no messages")
+	}
+
+	return &publish.Published{Ref: newRef(kind, msgs)}, nil
+}
+
+func (p *Publisher) Story(ctx context.Context, story *publish.Story) (*publish.Published, error) {
+	return nil, fmt.Errorf("telegram stories: %w", publish.ErrNotSupported)
+}
+
+func (p *Publisher) Edit(ctx context.Context, ref publish.Ref, caption string) error {
+	r, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	// the caption of a media group is the caption of its first message
+	if r.kind == refText {
+		return p.client.EditText(ctx, r.chatID, r.messageIDs[0], caption)
+	}
+	return p.client.EditCaption(ctx, r.chatID, r.messageIDs[0], caption)
+}
+
+func (p *Publisher) Delete(ctx context.Context, ref publish.Ref) error {
+	r, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range r.messageIDs {
+		if err := p.client.DeleteMessage(ctx, r.chatID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputMedia opens the staged media only once the upload starts.
+func inputMedia(m publish.Media) InputMedia {
+	mediaType := MediaPhoto
+	if m.Type == source.MediaTypeVideo {
+		mediaType = MediaVideo
+	}
+
+	url := m.URL
+	return InputMedia{
+		Type: mediaType,
+		Open: func() (io.ReadCloser, error) {
+			return downloader.DownloadFile(url)
+		},
+	}
+}
+
+// ref points to the messages of a published post.
+type ref struct {
+	kind       string
+	chatID     int64
+	messageIDs []int
+}
+
+// newRef formats the messages as kind:chat_id:message_id[,message_id...].
+func newRef(kind string, msgs []Message) publish.Ref {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, strconv.Itoa(msg.MessageID))
+	}
+	return publish.Ref(fmt.Sprintf("%s:%d:%s", kind, msgs[0].Chat.ID, strings.Join(ids, ",")))
+}
+
+func parseRef(s publish.Ref) (*ref, error) {
+	parts := strings.Split(string(s), ":")
+	if len(parts) != 3 || (parts[0] != refText && parts[0] != refMedia) {
+		return nil, fmt.Errorf("invalid telegram ref %q", s)
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid telegram ref %q", s)
+	}
+
+	r := &ref{kind: parts[0], chatID: chatID}
+	for _, id := range strings.Split(parts[2], ",") {
+		messageID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid telegram ref %q", s)
+		}
+		r.messageIDs = append(r.messageIDs, messageID)
+	}
+	return r, nil
+}
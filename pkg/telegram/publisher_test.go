@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// newTestPublisher returns a publisher talking to a stub Bot API server.
+func newTestPublisher(t *testing.T, handler http.Handler) (*Publisher, *httptest.Server) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient(config.TelegramConfig{BotToken: "token", ChatID: "@channel", API: server.URL})
+	return NewPublisher(client), server
+}
+
+func TestPostMediaGroup(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		fields map[string]string
+		files  map[string]string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/media/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content of " + strings.TrimPrefix(r.URL.Path, "/media/")))
+	})
+	mux.HandleFunc("/bottoken/sendMediaGroup", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		reader, err := r.MultipartReader()
+		if err != nil {
+			t.Errorf("sendMediaGroup is not multipart: %v", err)
+			return
+		}
+		fields, files = make(map[string]string), make(map[string]string)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("read part: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(part)
+			if part.FileName() != "" {
+				files[part.FormName()] = string(data)
+			} else {
+				fields[part.FormName()] = string(data)
+			}
+		}
+
+		w.Write([]byte(`{"ok":true,"result":[{"message_id":7,"chat":{"id":-100}},{"message_id":8,"chat":{"id":-100}}]}`))
+	})
+	p, server := newTestPublisher(t, mux)
+
+	// emoji take two UTF-16 code units each
+	caption := strings.Repeat("😀", maxCaption)
+	published, err := p.Post(context.Background(), &publish.Post{
+		Caption: caption,
+		Media: []publish.Media{
+			{Type: source.MediaTypeImage, URL: server.URL + "/media/a.jpg"},
+			{Type: source.MediaTypeVideo, URL: server.URL + "/media/b.mp4"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if published.Ref != "media:-100:7,8" {
+		t.Errorf("Ref = %q, want media:-100:7,8", published.Ref)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fields["chat_id"] != "@channel" {
+		t.Errorf("chat_id = %q, want @channel", fields["chat_id"])
+	}
+	if files["file0"] != "content of a.jpg" || files["file1"] != "content of b.mp4" {
+		t.Errorf("files = %q", files)
+	}
+
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(fields["media"]), &items); err != nil {
+		t.Fatalf("media field %q: %v", fields["media"], err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("media = %v, want two items", items)
+	}
+	if items[0]["type"] != MediaPhoto || items[0]["media"] != "attach://file0" {
+		t.Errorf("first item = %v", items[0])
+	}
+	if items[1]["type"] != MediaVideo || items[1]["media"] != "attach://file1" || items[1]["caption"] != nil {
+		t.Errorf("second item = %v", items[1])
+	}
+
+	got, _ := items[0]["caption"].(string)
+	if units := len(utf16.Encode([]rune(got))); units > maxCaption {
+		t.Errorf("caption has %d UTF-16 code units, want at most %d", units, maxCaption)
+	}
+	if want := strings.Repeat("😀", maxCaption/2-1) + "…"; got != want {
+		t.Errorf("caption = %d runes, want %d", len([]rune(got)), len([]rune(want)))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a longer text", 10, "a longer …"},
+		{"😀😀😀", 6, "😀😀😀"},
+		{"😀😀😀", 5, "😀😀…"},
+		{"😀😀😀", 4, "😀…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfterPauses(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	p, _ := newTestPublisher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`))
+	}))
+
+	_, err := p.Post(context.Background(), &publish.Post{Caption: "text"})
+	if err == nil {
+		t.Fatal("Post succeeded, want a flood error")
+	}
+	if err := p.Paused(); err == nil {
+		t.Fatal("publisher is not paused after retry_after")
+	}
+
+	// paused requests fail without reaching the Bot API
+	if _, err := p.Post(context.Background(), &publish.Post{Caption: "text"}); err == nil {
+		t.Error("Post succeeded while paused")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("Bot API got %d requests, want 1", requests)
+	}
+}
@@ -1,16 +1,56 @@
 package vk
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"time"
 
+	"github.com/SevereCloud/vksdk/v2/api"
 	"github.com/SevereCloud/vksdk/v2/api/params"
+	"github.com/SevereCloud/vksdk/v2/object"
 )
 
 type VideoParams struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	FileURL     string `json:"file_url"`
+	// VideoOverrides change the configured video settings for this upload
+	VideoOverrides
+}
+
+// Types of objects published to VK.
+const (
+	ObjectWall  = "wall"
+	ObjectVideo = "video"
+	ObjectStory = "story"
+	ObjectPhoto = "photo"
+)
+
+// Object identifies an object published to VK.
+type Object struct {
+	Type    string
+	OwnerID int
+	ID      int
+	// ExpiresAt is set for stories
+	ExpiresAt time.Time
+	// Video is the uploaded video a postponed wall post carries
+	Video *Object
+}
+
+// UploadedVideo returns the uploaded video of the object, which is either the
+// video itself or the video carried by a wall post, or nil.
+func (o *Object) UploadedVideo() *Object {
+	if o.Type == ObjectVideo {
+		return o
+	}
+	return o.Video
+}
+
+// Attachment returns the object in the VK attachment format, e.g. video1_2.
+func (o Object) Attachment() string {
+	return fmt.Sprintf("%s%d_%d", o.Type, o.OwnerID, o.ID)
 }
 
 func GetFileReader(path string) (io.Reader, error) {
@@ -22,52 +62,187 @@ func GetFileReader(path string) (io.Reader, error) {
 	return file, nil
 }
 
-func (c *Client) UploadVideo(name, description string, file io.Reader) error {
+// PostOptions are optional parameters of wall posts.
+type PostOptions struct {
+	// PublishAt postpones the post, zero publishes right away
+	PublishAt time.Time
+	// VideoAlbumID adds uploaded videos to the video album
+	VideoAlbumID int
+	// PhotoAlbumID uploads photos to the photo album instead of the wall
+	PhotoAlbumID int
+	// Video overrides the configured video settings when set
+	Video *VideoSettings
+}
+
+// UploadVideo uploads the video and, unless wallpost is disabled, posts it on
+// the wall. A postponed video is returned as the postponed wall post carrying
+// it. Only wall posts can be postponed, so scheduling needs wallpost.
+func (c *Client) UploadVideo(name, description string, file io.Reader, opts PostOptions) (*Object, error) {
+	settings := c.video
+	if opts.Video != nil {
+		settings = *opts.Video
+	}
+	postpone := !opts.PublishAt.IsZero()
+	if postpone && !settings.Wallpost {
+		return nil, errors.New("vk videos can only be scheduled as wall posts, wallpost is disabled")
+	}
+
 	p := params.NewVideoSaveBuilder()
-	p.Repeat(true)
+	p.Repeat(settings.Repeat)
 	p.Name(name)
 	p.Description(description)
 	p.Confirm(true)
-	p.Wallpost(true)
-	p.Compression(true)
+	p.Wallpost(settings.Wallpost && !postpone)
+	p.Compression(settings.Compression)
+	p.NoComments(settings.NoComments)
+	if len(settings.PrivacyView) > 0 {
+		p.PrivacyView(settings.PrivacyView)
+	}
+	if len(settings.PrivacyComment) > 0 {
+		p.PrivacyComment(settings.PrivacyComment)
+	}
+	if c.groupID != 0 {
+		p.GroupID(c.groupID)
+	}
+	if opts.VideoAlbumID != 0 {
+		p.AlbumID(opts.VideoAlbumID)
+	}
 
-	_, err := c.vk.UploadVideo(p.Params, file)
+	resp, err := c.vk.UploadVideo(p.Params, file)
 	if err != nil {
-		return err
+		return nil, c.pause.observe(err)
 	}
 
-	return nil
+	video := &Object{Type: ObjectVideo, OwnerID: resp.OwnerID, ID: resp.VideoID}
+	if postpone {
+		post, err := c.PostWall(description, opts, video.Attachment())
+		if err != nil {
+			return nil, err
+		}
+		post.Video = video
+		return post, nil
+	}
+	return video, nil
 }
 
-func (c *Client) UploadWallPhoto(name, description string, file io.Reader) error {
-	_, err := c.vk.UploadWallPhoto(file)
+// UploadPhoto uploads the photo for a wall post. With a photo album set the
+// photo is kept in that album.
+func (c *Client) UploadPhoto(file io.Reader, opts PostOptions) (*Object, error) {
+	var photos []object.PhotosPhoto
+	var err error
+	switch {
+	case opts.PhotoAlbumID != 0 && c.groupID != 0:
+		photos, err = c.vk.UploadPhotoGroup(c.groupID, opts.PhotoAlbumID, file)
+	case opts.PhotoAlbumID != 0:
+		photos, err = c.vk.UploadPhoto(opts.PhotoAlbumID, file)
+	case c.groupID != 0:
+		photos, err = c.vk.UploadGroupWallPhoto(c.groupID, file)
+	default:
+		photos, err = c.vk.UploadWallPhoto(file)
+	}
 	if err != nil {
-		return err
+		return nil, c.pause.observe(err)
+	}
+	if len(photos) == 0 {
+		return nil, errors.New("vk returned no saved photos")
 	}
 
-	return nil
+	return &Object{Type: ObjectPhoto, OwnerID: photos[0].OwnerID, ID: photos[0].ID}, nil
 }
 
-func (c *Client) UploadStoryVideo(file io.Reader) error {
+// PostWall publishes a wall post with the message and attachments.
+func (c *Client) PostWall(message string, opts PostOptions, attachments ...string) (*Object, error) {
+	p := params.NewWallPostBuilder()
+	p.OwnerID(c.owner())
+	p.Message(message)
+	if c.groupID != 0 {
+		p.FromGroup(c.fromGroup)
+		p.Signed(c.signed)
+	}
+	if len(attachments) > 0 {
+		p.Attachments(attachments)
+	}
+	if !opts.PublishAt.IsZero() {
+		p.PublishDate(int(opts.PublishAt.Unix()))
+	}
+
+	resp, err := c.vk.WallPost(p.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{Type: ObjectWall, OwnerID: c.owner(), ID: resp.PostID}, nil
+}
+
+// StoryOptions are optional stories.get*UploadServer parameters.
+type StoryOptions struct {
+	// LinkText is one of the VK link button texts, e.g. "more" or "open"
+	LinkText     string
+	LinkURL      string
+	ReplyToStory string
+	UserIDs      []int
+}
+
+func (c *Client) UploadStoryVideo(file io.Reader, opts StoryOptions) (*Object, error) {
 	p := params.NewStoriesGetVideoUploadServerBuilder()
 	p.AddToNews(true)
+	if c.groupID != 0 {
+		p.GroupID(c.groupID)
+	}
+	if opts.LinkURL != "" {
+		p.LinkText(opts.LinkText)
+		p.LinkURL(opts.LinkURL)
+	}
+	if opts.ReplyToStory != "" {
+		p.ReplyToStory(opts.ReplyToStory)
+	}
+	if len(opts.UserIDs) > 0 {
+		p.UserIDs(opts.UserIDs)
+	}
 
-	_, err := c.vk.UploadStoriesVideo(p.Params, file)
+	resp, err := c.vk.UploadStoriesVideo(p.Params, file)
 	if err != nil {
-		return err
+		return nil, c.pause.observe(err)
 	}
 
-	return nil
+	return storyObject(resp)
 }
 
-func (c *Client) UploadStoryPhoto(file io.Reader) error {
+func (c *Client) UploadStoryPhoto(file io.Reader, opts StoryOptions) (*Object, error) {
 	p := params.NewStoriesGetPhotoUploadServerBuilder()
 	p.AddToNews(true)
+	if c.groupID != 0 {
+		p.GroupID(c.groupID)
+	}
+	if opts.LinkURL != "" {
+		p.LinkText(opts.LinkText)
+		p.LinkURL(opts.LinkURL)
+	}
+	if opts.ReplyToStory != "" {
+		p.ReplyToStory(opts.ReplyToStory)
+	}
+	if len(opts.UserIDs) > 0 {
+		p.UserIDs(opts.UserIDs)
+	}
 
-	_, err := c.vk.UploadStoriesPhoto(p.Params, file)
+	resp, err := c.vk.UploadStoriesPhoto(p.Params, file)
 	if err != nil {
-		return err
+		return nil, c.pause.observe(err)
+	}
+
+	return storyObject(resp)
+}
+
+func storyObject(resp api.StoriesSaveResponse) (*Object, error) {
+	if len(resp.Items) == 0 {
+		return nil, errors.New("vk returned no saved stories")
 	}
 
-	return nil
+	story := resp.Items[0]
+	return &Object{
+		Type:      ObjectStory,
+		OwnerID:   story.OwnerID,
+		ID:        story.ID,
+		ExpiresAt: time.Unix(int64(story.ExpiresAt), 0),
+	}, nil
 }
@@ -0,0 +1,147 @@
+package vk
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+
+	"github.com/SevereCloud/vksdk/v2/api/params"
+)
+
+// Kinds of VK albums.
+const (
+	AlbumVideo = "video"
+	AlbumPhoto = "photo"
+)
+
+// albumsPageSize is the maximum count of albums.get* methods.
+const albumsPageSize = 100
+
+// Owner returns the id of the wall objects are published to, negative for
+// communities.
+func (c *Client) Owner() int {
+	return c.owner()
+}
+
+// FindAlbum returns the id of the owner's album with the given title or 0 if
+// there is no such album.
+func (c *Client) FindAlbum(kind, title string) (int, error) {
+	switch kind {
+	case AlbumVideo:
+		return c.findVideoAlbum(title)
+	case AlbumPhoto:
+		return c.findPhotoAlbum(title)
+	default:
+		return 0, fmt.Errorf("unknown vk album kind %q", kind)
+	}
+}
+
+// CreateAlbum creates an album with the given title and returns its id.
+func (c *Client) CreateAlbum(kind, title string) (int, error) {
+	switch kind {
+	case AlbumVideo:
+		p := params.NewVideoAddAlbumBuilder()
+		p.Title(title)
+		if c.groupID != 0 {
+			p.GroupID(c.groupID)
+		}
+
+		resp, err := c.vk.VideoAddAlbum(p.Params)
+		if err != nil {
+			return 0, err
+		}
+		return resp.AlbumID, nil
+	case AlbumPhoto:
+		p := params.NewPhotosCreateAlbumBuilder()
+		p.Title(title)
+		if c.groupID != 0 {
+			p.GroupID(c.groupID)
+		}
+
+		resp, err := c.vk.PhotosCreateAlbum(p.Params)
+		if err != nil {
+			return 0, err
+		}
+		return resp.ID, nil
+	default:
+		return 0, fmt.Errorf("unknown vk album kind %q", kind)
+	}
+}
+
+func (c *Client) findVideoAlbum(title string) (int, error) {
+	for offset := 0; ; offset += albumsPageSize {
+		p := params.NewVideoGetAlbumsBuilder()
+		p.OwnerID(c.owner())
+		p.Offset(offset)
+		p.Count(albumsPageSize)
+
+		resp, err := c.vk.VideoGetAlbums(p.Params)
+		if err != nil {
+			return 0, err
+		}
+		for _, album := range resp.Items {
+			if album.Title == title {
+				return album.ID, nil
+			}
+		}
+		if len(resp.Items) < albumsPageSize {
+			return 0, nil
+		}
+	}
+}
+
+func (c *Client) findPhotoAlbum(title string) (int, error) {
+	for offset := 0; ; offset += albumsPageSize {
+		p := params.NewPhotosGetAlbumsBuilder()
+		p.OwnerID(c.owner())
+		p.Offset(offset)
+		p.Count(albumsPageSize)
+
+		resp, err := c.vk.PhotosGetAlbums(p.Params)
+		if err != nil {
+			return 0, err
+		}
+		for _, album := range resp.Items {
+			if album.Title == title {
+				return album.ID, nil
+			}
+		}
+		if len(resp.Items) < albumsPageSize {
+			return 0, nil
+		}
+	}
+}
+
+// matchAlbumRule returns the first rule matching the item or nil.
+func matchAlbumRule(rules []config.AlbumRule, item *source.Item) *config.AlbumRule {
+	for i, rule := range rules {
+		if rule.MediaType != "" && !strings.EqualFold(rule.MediaType, item.MediaType) {
+			continue
+		}
+		if rule.Hashtag != "" && !hasHashtag(item.Caption, rule.Hashtag) {
+			continue
+		}
+		return &rules[i]
+	}
+	return nil
+}
+
+// hasHashtag reports whether the caption contains the hashtag, ignoring case.
+func hasHashtag(caption, hashtag string) bool {
+	hashtag = strings.TrimPrefix(hashtag, "#")
+	for _, word := range strings.Fields(caption) {
+		if !strings.HasPrefix(word, "#") {
+			continue
+		}
+		tag := strings.TrimRightFunc(word[1:], func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		if strings.EqualFold(tag, hashtag) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,13 +8,19 @@ import (
 	"github.com/SevereCloud/vksdk/v2/api"
 )
 
-// Ensure that Client implements the Uploader interface.
-var _ Uploader = (*Client)(nil)
-
 type Client struct {
 	vk      *api.VK
 	token   string
 	ownerID int
+	// groupID is set when publishing to a community wall
+	groupID   int
+	fromGroup bool
+	signed    bool
+	// video holds the default video.save options
+	video VideoSettings
+	pause *pause
+	// limiter is shared by all clients of the token
+	limiter *limiter
 }
 
 func NewClient(config config.VKConfig) *Client {
@@ -23,9 +29,42 @@ func NewClient(config config.VKConfig) *Client {
 		token = config.AccessToken
 	}
 
-	return &Client{
-		vk:      api.NewVK(token),
-		token:   token,
-		ownerID: config.OwnerID,
+	// a negative owner id is a community
+	groupID := config.GroupID
+	if groupID == 0 && config.OwnerID < 0 {
+		groupID = -config.OwnerID
+	}
+
+	// set default fallback values
+	rate := config.RequestsPerSecond
+	if rate <= 0 {
+		rate = defaultRequestsPerSecond
+	}
+
+	c := &Client{
+		vk:        api.NewVK(token),
+		token:     token,
+		ownerID:   config.OwnerID,
+		groupID:   groupID,
+		fromGroup: config.FromGroup == nil || *config.FromGroup,
+		signed:    config.Signed,
+		video:     newVideoSettings(config.Video),
+		pause:     &pause{},
+		limiter:   limiterFor(token, rate),
+	}
+	// requests are limited per token by the shared limiter instead of the
+	// per client limit of vksdk
+	c.vk.Limit = 0
+	c.vk.Handler = c.handle
+
+	return c
+}
+
+// owner returns the id of the wall objects are published to, negative for
+// communities.
+func (c *Client) owner() int {
+	if c.groupID != 0 {
+		return -c.groupID
 	}
+	return c.ownerID
 }
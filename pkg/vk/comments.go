@@ -0,0 +1,40 @@
+package vk
+
+import (
+	"fmt"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// CreateComment leaves a comment with the message under the published object
+// and returns the comment id. Stories can't be commented.
+func (c *Client) CreateComment(obj Object, message string) (int, error) {
+	switch obj.Type {
+	case ObjectWall:
+		p := api.Params{
+			"owner_id": obj.OwnerID,
+			"post_id":  obj.ID,
+			"message":  message,
+		}
+		if c.groupID != 0 && c.fromGroup {
+			p["from_group"] = c.groupID
+		}
+		resp, err := c.vk.WallCreateComment(p)
+		if err != nil {
+			return 0, err
+		}
+		return resp.CommentID, nil
+	case ObjectVideo:
+		p := api.Params{
+			"owner_id": obj.OwnerID,
+			"video_id": obj.ID,
+			"message":  message,
+		}
+		if c.groupID != 0 && c.fromGroup {
+			p["from_group"] = true
+		}
+		return c.vk.VideoCreateComment(p)
+	default:
+		return 0, fmt.Errorf("comments are not supported for vk %s", obj.Type)
+	}
+}
@@ -0,0 +1,72 @@
+package vk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+type thumbUploadURLResponse struct {
+	UploadURL string `json:"upload_url"`
+}
+
+// SetVideoCover uploads the image and makes it the cover of the video. VK
+// picks a frame of the video as the cover otherwise.
+func (c *Client) SetVideoCover(video Object, image io.Reader) error {
+	var server thumbUploadURLResponse
+	err := c.vk.RequestUnmarshal("video.getThumbUploadUrl", &server, api.Params{
+		"owner_id": video.OwnerID,
+	})
+	if err != nil {
+		return err
+	}
+	if server.UploadURL == "" {
+		return errors.New("vk returned no thumb upload url")
+	}
+
+	thumb, err := c.vk.UploadFile(server.UploadURL, image, "file", "cover.jpg")
+	if err != nil {
+		return c.pause.observe(err)
+	}
+
+	var resp interface{}
+	return c.vk.RequestUnmarshal("video.saveUploadedThumb", &resp, api.Params{
+		"owner_id":   video.OwnerID,
+		"video_id":   video.ID,
+		"thumb_json": string(thumb),
+		"set_thumb":  1,
+	})
+}
+
+// coverFrameOffset is where the cover frame is taken from when the item has
+// no thumbnail.
+const coverFrameOffset = "00:00:01"
+
+// extractFrame grabs a single frame of the video with ffmpeg.
+func extractFrame(ctx context.Context, ffmpeg, videoURL string) (io.Reader, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-loglevel", "error",
+		"-ss", coverFrameOffset,
+		"-i", videoURL,
+		"-frames:v", "1",
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"pipe:1",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg extracted no frame from %s", videoURL)
+	}
+
+	return &stdout, nil
+}
@@ -0,0 +1,95 @@
+package vk
+
+import (
+	"fmt"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// ErrNotSupported is returned for actions VK doesn't support on the object.
+var ErrNotSupported = fmt.Errorf("vk: %w", publish.ErrNotSupported)
+
+// EditCaption replaces the text of the published object. Wall posts keep
+// their photo and video attachments. Stories have no caption.
+func (c *Client) EditCaption(obj Object, caption string) error {
+	switch obj.Type {
+	case ObjectWall:
+		return c.editPost(obj, caption)
+	case ObjectVideo:
+		_, err := c.vk.VideoEdit(api.Params{
+			"owner_id": obj.OwnerID,
+			"video_id": obj.ID,
+			"name":     caption,
+			"desc":     caption,
+		})
+		return err
+	default:
+		return fmt.Errorf("caption of vk %s: %w", obj.Type, ErrNotSupported)
+	}
+}
+
+func (c *Client) editPost(obj Object, caption string) error {
+	posts, err := c.vk.WallGetByID(api.Params{
+		"posts": fmt.Sprintf("%d_%d", obj.OwnerID, obj.ID),
+	})
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return fmt.Errorf("vk post %d_%d not found", obj.OwnerID, obj.ID)
+	}
+
+	// wall.edit drops attachments that are not passed again
+	var attachments []string
+	for _, a := range posts[0].Attachments {
+		switch a.Type {
+		case "photo":
+			attachments = append(attachments, a.Photo.ToAttachment())
+		case "video":
+			attachments = append(attachments, a.Video.ToAttachment())
+		}
+	}
+
+	_, err = c.vk.WallEdit(api.Params{
+		"owner_id":    obj.OwnerID,
+		"post_id":     obj.ID,
+		"message":     caption,
+		"attachments": attachments,
+	})
+	return err
+}
+
+// Delete removes the published object.
+func (c *Client) Delete(obj Object) error {
+	var err error
+	switch obj.Type {
+	case ObjectWall:
+		_, err = c.vk.WallDelete(api.Params{"owner_id": obj.OwnerID, "post_id": obj.ID})
+	case ObjectVideo:
+		_, err = c.vk.VideoDelete(api.Params{"owner_id": obj.OwnerID, "video_id": obj.ID})
+	case ObjectStory:
+		_, err = c.vk.StoriesDelete(api.Params{"owner_id": obj.OwnerID, "story_id": obj.ID})
+	case ObjectPhoto:
+		_, err = c.vk.PhotosDelete(api.Params{"owner_id": obj.OwnerID, "photo_id": obj.ID})
+	default:
+		err = fmt.Errorf("delete vk %s: %w", obj.Type, ErrNotSupported)
+	}
+	return err
+}
+
+// Archive hides the published object without deleting it. Only videos can be
+// hidden, the API has no way to archive wall posts.
+func (c *Client) Archive(obj Object) error {
+	if obj.Type != ObjectVideo {
+		return fmt.Errorf("archive vk %s: %w", obj.Type, ErrNotSupported)
+	}
+
+	_, err := c.vk.VideoEdit(api.Params{
+		"owner_id":     obj.OwnerID,
+		"video_id":     obj.ID,
+		"privacy_view": "only_me",
+	})
+	return err
+}
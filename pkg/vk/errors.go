@@ -0,0 +1,246 @@
+package vk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// ErrorKind classifies VK errors by how callers should react to them.
+type ErrorKind string
+
+const (
+	// ErrorKindAuth means the token is invalid or expired, nothing works
+	// until it is replaced
+	ErrorKindAuth ErrorKind = "auth"
+	// ErrorKindTooManyRequests means the per second limit was hit
+	ErrorKindTooManyRequests ErrorKind = "too_many_requests"
+	// ErrorKindFlood means flood control, e.g. too many posts a day
+	ErrorKindFlood ErrorKind = "flood_control"
+	// ErrorKindCaptcha means VK wants a captcha solved
+	ErrorKindCaptcha ErrorKind = "captcha"
+	// ErrorKindAccessDenied means the token lacks rights for the object
+	ErrorKindAccessDenied ErrorKind = "access_denied"
+	// ErrorKindUpload means the upload server rejected the file
+	ErrorKindUpload ErrorKind = "upload"
+)
+
+var apiErrorKinds = map[api.ErrorType]ErrorKind{
+	api.ErrAuth:        ErrorKindAuth,
+	api.ErrTooMany:     ErrorKindTooManyRequests,
+	api.ErrFlood:       ErrorKindFlood,
+	api.ErrCaptcha:     ErrorKindCaptcha,
+	api.ErrAccess:      ErrorKindAccessDenied,
+	api.ErrAccessAlbum: ErrorKindAccessDenied,
+	api.ErrUpload:      ErrorKindUpload,
+}
+
+// pauses holds how long publishing is paused after an error of the kind.
+var pauses = map[ErrorKind]time.Duration{
+	ErrorKindTooManyRequests: time.Minute,
+	ErrorKindFlood:           time.Hour,
+	ErrorKindCaptcha:         15 * time.Minute,
+}
+
+// authPause is how long publishing is paused after an auth error. The token
+// is checked again after it and the pause extended while it still fails.
+const authPause = 30 * time.Minute
+
+// Error is a classified VK API or upload error.
+type Error struct {
+	Kind ErrorKind
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("vk %s error: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify wraps VK errors of known kinds into *Error, other errors are
+// returned as is.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		kind, ok := apiErrorKinds[apiErr.Code]
+		if !ok {
+			return err
+		}
+		return &Error{Kind: kind, Code: int(apiErr.Code), Err: err}
+	}
+
+	var uploadErr *api.UploadError
+	if errors.As(err, &uploadErr) {
+		return &Error{Kind: ErrorKindUpload, Code: uploadErr.Code, Err: err}
+	}
+
+	return err
+}
+
+// KindOf returns the kind of a classified error or an empty kind.
+func KindOf(err error) ErrorKind {
+	var classified *Error
+	if errors.As(Classify(err), &classified) {
+		return classified.Kind
+	}
+	return ""
+}
+
+// rejectedUploads are markers of upload server errors about the file itself,
+// e.g. "file too large" or "wrong format".
+var rejectedUploads = []string{"too large", "too big", "size", "format", "extension"}
+
+// Rejected reports whether VK will never take the item: the upload server
+// refused the file as too large or in a format it doesn't support. Photos
+// saved with such a file fail with invalid photos (122) or invalid photo
+// (129). Other errors may go away on retry.
+func Rejected(err error) bool {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == api.ErrParamPhotos || apiErr.Code == api.ErrParamPhoto
+	}
+
+	var uploadErr *api.UploadError
+	if errors.As(err, &uploadErr) {
+		reason := strings.ToLower(uploadErr.Err + " " + uploadErr.Descr)
+		for _, marker := range rejectedUploads {
+			if strings.Contains(reason, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// staleAlbum reports whether the error may come from an album id that is
+// cached but was deleted on VK.
+func staleAlbum(err error) bool {
+	var apiErr *api.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == api.ErrParam || apiErr.Code == api.ErrParamAlbumID)
+}
+
+// pause stops requests of the client after errors that would only repeat
+// when retried right away.
+type pause struct {
+	mu    sync.Mutex
+	until time.Time
+	// auth is set after auth errors, the pause only ends once the token
+	// works again
+	auth  bool
+	cause error
+}
+
+// observe classifies the error and pauses the client if the error kind
+// calls for it.
+func (p *pause) observe(err error) error {
+	err = Classify(err)
+
+	kind := KindOf(err)
+	if kind == "" {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if kind == ErrorKindAuth {
+		p.auth = true
+		p.cause = err
+		if until := time.Now().Add(authPause); until.After(p.until) {
+			p.until = until
+		}
+	} else if d, ok := pauses[kind]; ok {
+		if until := time.Now().Add(d); until.After(p.until) {
+			p.until = until
+			p.cause = err
+		}
+	}
+	return err
+}
+
+// err returns an error wrapping the cause while the client is paused.
+func (p *pause) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !time.Now().Before(p.until) {
+		return nil
+	}
+	if p.auth {
+		return fmt.Errorf("vk requests are paused until %s, then the token is checked again: %w", p.until.Format(time.RFC3339), p.cause)
+	}
+	return fmt.Errorf("vk requests are paused until %s: %w", p.until.Format(time.RFC3339), p.cause)
+}
+
+// recheck reports whether an auth pause is over and the token has to be
+// checked before requests go on. Only one caller gets true, the pause is
+// extended for the others meanwhile.
+func (p *pause) recheck() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.auth || time.Now().Before(p.until) {
+		return false
+	}
+	p.until = time.Now().Add(authPause)
+	return true
+}
+
+// recovered ends the auth pause.
+func (p *pause) recovered() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.auth = false
+	p.until = time.Time{}
+	p.cause = nil
+}
+
+// Paused returns a non-nil error while requests are paused after auth,
+// flood control, captcha or rate limit errors. The error wraps the cause.
+// Once an auth pause is over the token is checked with users.get, requests
+// only go on if it works again.
+func (c *Client) Paused() error {
+	if c.pause.recheck() {
+		c.limiter.wait()
+		_, err := c.vk.DefaultHandler("users.get", api.Params{})
+		if KindOf(err) == ErrorKindAuth {
+			log.Printf("[client:vk] Token still fails, pausing requests for %s: %v\n", authPause, err)
+			c.pause.observe(err)
+		} else {
+			log.Println("[client:vk] Token works again, resuming requests")
+			c.pause.recovered()
+		}
+	}
+	return c.pause.err()
+}
+
+// handle is the vksdk request handler, it refuses requests while the client
+// is paused, waits for the rate limiter and classifies the errors.
+func (c *Client) handle(method string, params ...api.Params) (api.Response, error) {
+	if err := c.Paused(); err != nil {
+		return api.Response{}, err
+	}
+	c.limiter.wait()
+
+	resp, err := c.vk.DefaultHandler(method, params...)
+	return resp, c.pause.observe(err)
+}
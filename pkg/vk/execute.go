@@ -0,0 +1,59 @@
+package vk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// executeLimit is the maximum number of API calls in one execute request.
+const executeLimit = 25
+
+// call is a single API call batched into an execute request.
+type call struct {
+	method string
+	params api.Params
+}
+
+// execute runs the calls in execute requests of up to executeLimit calls and
+// returns their responses in order. The response of a failed call is false.
+func (c *Client) execute(calls []call) ([]json.RawMessage, error) {
+	responses := make([]json.RawMessage, 0, len(calls))
+	for start := 0; start < len(calls); start += executeLimit {
+		end := start + executeLimit
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		var code strings.Builder
+		code.WriteString("return [")
+		for i, cl := range calls[start:end] {
+			args, err := json.Marshal(cl.params)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				code.WriteString(",")
+			}
+			fmt.Fprintf(&code, "API.%s(%s)", cl.method, args)
+		}
+		code.WriteString("];")
+
+		// errors of single calls are reported along with the responses
+		var batch []json.RawMessage
+		err := c.vk.Execute(code.String(), &batch)
+		var callErrs *api.ExecuteErrors
+		if err != nil && !(errors.As(err, &callErrs) && batch != nil) {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("vk execute returned %d responses for %d calls", len(batch), end-start)
+		}
+		responses = append(responses, batch...)
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,56 @@
+package vk
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultRequestsPerSecond is the VK API limit of user tokens.
+const defaultRequestsPerSecond = 3
+
+var (
+	limitersMu sync.Mutex
+	// limiters are shared by all clients of the same token in the process,
+	// e.g. the workers and the upload server of the daemon
+	limiters = map[string]*limiter{}
+)
+
+// limiter is a token bucket allowing rate requests per second with bursts of
+// up to rate requests.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// limiterFor returns the limiter of the token, creating it with the given
+// rate on first use.
+func limiterFor(token string, rate float64) *limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	l, ok := limiters[token]
+	if !ok {
+		l = &limiter{rate: rate, tokens: rate, last: time.Now()}
+		limiters[token] = l
+	}
+	return l
+}
+
+// wait blocks until a request may be made.
+func (l *limiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// a negative balance reserves a slot in the future
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
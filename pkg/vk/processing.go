@@ -0,0 +1,84 @@
+package vk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// ErrProcessingTimeout is returned by WaitVideo when VK did not finish
+// processing the video in time.
+var ErrProcessingTimeout = errors.New("vk video processing timed out")
+
+// VideoFailedError is returned by WaitVideo when VK failed to process the
+// video.
+type VideoFailedError struct {
+	Reason string
+}
+
+func (e *VideoFailedError) Error() string {
+	return fmt.Sprintf("vk video processing failed: %s", e.Reason)
+}
+
+// videoPollInterval is how often WaitVideo checks the video.
+const videoPollInterval = 10 * time.Second
+
+// WaitVideo polls the uploaded video until VK has processed it. It returns
+// nil once the video is playable, a *VideoFailedError with the VK reason if
+// processing failed and ErrProcessingTimeout after timeout. Failed checks
+// are retried until the timeout.
+func (c *Client) WaitVideo(ctx context.Context, video Object, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := c.videoProcessed(video)
+		var failed *VideoFailedError
+		if done || errors.As(err, &failed) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrProcessingTimeout, err)
+			}
+			return ErrProcessingTimeout
+		}
+
+		select {
+		case <-time.After(videoPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// videoProcessed reports whether VK has finished processing the video.
+func (c *Client) videoProcessed(video Object) (bool, error) {
+	resp, err := c.vk.VideoGet(api.Params{
+		"owner_id": video.OwnerID,
+		"videos":   objectKey(video),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// VK drops videos it failed to transcode
+	if len(resp.Items) == 0 {
+		return false, &VideoFailedError{Reason: "video was removed by vk"}
+	}
+
+	item := resp.Items[0]
+	if item.ContentRestricted != 0 {
+		reason := item.ContentRestrictedMessage
+		if reason == "" {
+			reason = item.Restriction.Title
+		}
+		if reason == "" {
+			reason = "content restricted"
+		}
+		return false, &VideoFailedError{Reason: reason}
+	}
+
+	return item.Processing == 0, nil
+}
@@ -0,0 +1,448 @@
+package vk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/config"
+	"github.com/s-yakubovskiy/inst2vk/pkg/db"
+	"github.com/s-yakubovskiy/inst2vk/pkg/downloader"
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+	"github.com/s-yakubovskiy/inst2vk/pkg/source"
+)
+
+// Ensure that Publisher implements the publish.Publisher interface.
+var _ publish.Publisher = (*Publisher)(nil)
+
+// Destination is the name of the VK publisher in sync profiles.
+const Destination = "vk"
+
+// maxAttachments is the attachment limit of wall posts.
+const maxAttachments = 10
+
+// Publisher publishes items to the VK wall and stories. It places media into
+// albums, sets video covers and waits for VK to process uploaded videos.
+type Publisher struct {
+	client   *Client
+	cfg      config.VKConfig
+	database *sql.DB
+	// cover sets the item thumbnail as the cover of uploaded videos
+	cover bool
+	// processingTimeout bounds the wait for VK to process uploaded videos
+	processingTimeout time.Duration
+	// maxProcessingWait bounds the wait over all attempts of an item
+	maxProcessingWait time.Duration
+}
+
+func NewPublisher(cfg *config.Config, client *Client, database *sql.DB) *Publisher {
+	// set default fallback values
+	processingTimeout := cfg.VK.Video.ProcessingTimeout
+	if processingTimeout <= 0 {
+		processingTimeout = 120
+	}
+	maxProcessingWait := cfg.VK.Video.MaxProcessingWait
+	if maxProcessingWait <= 0 {
+		maxProcessingWait = 10800
+	}
+	if cfg.VK.Video.FFmpegPath == "" {
+		cfg.VK.Video.FFmpegPath = "ffmpeg"
+	}
+
+	return &Publisher{
+		client:            client,
+		cfg:               cfg.VK,
+		database:          database,
+		cover:             cfg.VK.Video.Cover == nil || *cfg.VK.Video.Cover,
+		processingTimeout: time.Duration(processingTimeout) * time.Second,
+		maxProcessingWait: time.Duration(maxProcessingWait) * time.Second,
+	}
+}
+
+func (p *Publisher) Name() string {
+	return Destination
+}
+
+func (p *Publisher) Capabilities() publish.Capabilities {
+	return publish.Capabilities{
+		Text:           true,
+		Photos:         true,
+		Videos:         true,
+		MaxAttachments: maxAttachments,
+		Schedule:       true,
+		Stories:        true,
+		Edit:           true,
+		Delete:         true,
+	}
+}
+
+// Paused returns why VK requests are paused or nil.
+func (p *Publisher) Paused() error {
+	return p.client.Paused()
+}
+
+func (p *Publisher) Post(ctx context.Context, post *publish.Post) (*publish.Published, error) {
+	opts := PostOptions{PublishAt: post.PublishAt}
+
+	var obj *Object
+	var err error
+	switch {
+	case post.Processing != "":
+		obj, err = p.resume(ctx, post, opts)
+	case len(post.Media) == 0:
+		obj, err = p.client.PostWall(post.Caption, opts)
+	case len(post.Media) == 1:
+		obj, err = p.postSingle(ctx, post, opts)
+	default:
+		obj, err = p.postCarousel(ctx, post, opts)
+	}
+	if staleAlbum(err) {
+		p.forgetAlbums(post.Item)
+	}
+	if Rejected(err) {
+		return nil, publish.Rejected(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &publish.Published{Ref: obj.Ref()}, nil
+}
+
+// postSingle uploads the photo or video and publishes it on the wall.
+func (p *Publisher) postSingle(ctx context.Context, post *publish.Post, opts PostOptions) (*Object, error) {
+	media := post.Media[0]
+	if err := p.albumOptions(post.Item, media.Type, &opts); err != nil {
+		return nil, fmt.Errorf("resolve albums: %w", err)
+	}
+
+	body, err := download(media.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// uploading only stores the photo, the wall post is what shows it on the
+	// wall like a wallpost video and what the insights worker reads stats of
+	if media.Type == source.MediaTypeImage {
+		photo, err := p.client.UploadPhoto(body, opts)
+		if err != nil {
+			return nil, err
+		}
+		return p.postParts(post.Caption, opts, []*Object{photo})
+	}
+
+	obj, err := p.client.UploadVideo(post.Caption, post.Caption, body, opts)
+	if err != nil {
+		return nil, err
+	}
+	video := obj.UploadedVideo()
+	p.setCoverOf(ctx, video, media)
+
+	uploaded := []*Object{obj}
+	if video != obj {
+		uploaded = append(uploaded, video)
+	}
+	if err := p.awaitVideos(ctx, processingRef(false, uploaded), uploaded, p.processingTimeout); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// postCarousel uploads every part of a carousel and publishes them in one
+// wall post once VK processed the videos. When a part or the post fails, the
+// parts uploaded so far are deleted so a retry does not leave them behind.
+func (p *Publisher) postCarousel(ctx context.Context, post *publish.Post, opts PostOptions) (*Object, error) {
+	parts, err := p.uploadParts(ctx, post)
+	if err != nil {
+		p.deleteAll(parts)
+		return nil, err
+	}
+
+	err = p.awaitVideos(ctx, processingRef(true, parts), parts, p.processingTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return p.postParts(post.Caption, opts, parts)
+}
+
+// postParts publishes uploaded photos and videos, e.g. the parts of a
+// carousel, in one wall post. They are deleted if that fails.
+func (p *Publisher) postParts(caption string, opts PostOptions, parts []*Object) (*Object, error) {
+	attachments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		attachments = append(attachments, part.Attachment())
+	}
+
+	obj, err := p.client.PostWall(caption, opts, attachments...)
+	if err != nil {
+		p.deleteAll(parts)
+		return nil, err
+	}
+	return obj, nil
+}
+
+// resume checks the uploads of an earlier attempt VK was still processing.
+// Once processed a single video is published already, a carousel is posted.
+// Uploads VK did not process within maxProcessingWait are deleted and the
+// attempt fails, so the item is uploaded again or given up on like any
+// failed item.
+func (p *Publisher) resume(ctx context.Context, post *publish.Post, opts PostOptions) (*Object, error) {
+	carousel, uploaded, err := parseProcessingRef(post.Processing)
+	if err != nil {
+		return nil, err
+	}
+
+	if !post.ProcessingSince.IsZero() && time.Since(post.ProcessingSince) > p.maxProcessingWait {
+		p.deleteAll(uploaded)
+		return nil, fmt.Errorf("vk did not process %s in %s: %w", post.Processing, p.maxProcessingWait, ErrProcessingTimeout)
+	}
+
+	// one check, the upload had its wait on the first attempt
+	if err := p.awaitVideos(ctx, post.Processing, uploaded, 0); err != nil {
+		return nil, err
+	}
+	if carousel {
+		return p.postParts(post.Caption, opts, uploaded)
+	}
+	return uploaded[0], nil
+}
+
+// uploadParts uploads the parts of a carousel. On failure it returns the
+// parts uploaded so far together with the error.
+func (p *Publisher) uploadParts(ctx context.Context, post *publish.Post) ([]*Object, error) {
+	// parts are not posted on their own
+	settings := p.client.VideoSettings()
+	settings.Wallpost = false
+
+	var parts []*Object
+	for _, media := range post.Media {
+		opts := PostOptions{Video: &settings}
+		if err := p.albumOptions(post.Item, media.Type, &opts); err != nil {
+			return parts, fmt.Errorf("resolve albums: %w", err)
+		}
+
+		part, err := p.uploadPart(ctx, post, media, opts)
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// uploadPart uploads a part of a carousel.
+func (p *Publisher) uploadPart(ctx context.Context, post *publish.Post, media publish.Media, opts PostOptions) (*Object, error) {
+	body, err := download(media.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if media.Type == source.MediaTypeImage {
+		return p.client.UploadPhoto(body, opts)
+	}
+
+	obj, err := p.client.UploadVideo(post.Caption, post.Caption, body, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.setCoverOf(ctx, obj.UploadedVideo(), media)
+	return obj, nil
+}
+
+// setCoverOf sets the cover of the uploaded video. The video is already
+// uploaded, a missing cover is not worth a retry.
+func (p *Publisher) setCoverOf(ctx context.Context, video *Object, media publish.Media) {
+	if !p.cover || video == nil {
+		return
+	}
+	if err := p.setCover(ctx, *video, media); err != nil {
+		log.Printf("[publisher:vk] Failed to set video cover of %d_%d: %v\n", video.OwnerID, video.ID, err)
+	}
+}
+
+// awaitVideos waits for VK to process the uploaded videos. Videos still
+// processing after the timeout are kept, the *publish.ProcessingError carries
+// ref so the next attempt checks them instead of uploading again. Uploads
+// VK failed to process are deleted, the next attempt starts over.
+func (p *Publisher) awaitVideos(ctx context.Context, ref publish.Ref, uploaded []*Object, timeout time.Duration) error {
+	for _, obj := range uploaded {
+		if obj.Type != ObjectVideo {
+			continue
+		}
+
+		err := p.client.WaitVideo(ctx, *obj, timeout)
+		if errors.Is(err, ErrProcessingTimeout) || (err != nil && ctx.Err() != nil) {
+			return &publish.ProcessingError{Ref: ref, Err: err}
+		}
+		if err != nil {
+			p.deleteAll(uploaded)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAll deletes the uploaded objects, logging failures.
+func (p *Publisher) deleteAll(objs []*Object) {
+	for _, obj := range objs {
+		if err := p.client.Delete(*obj); err != nil {
+			log.Printf("[publisher:vk] Failed to delete vk %s %d_%d: %v\n", obj.Type, obj.OwnerID, obj.ID, err)
+		}
+	}
+}
+
+// setCover makes the thumbnail of the media, or a frame of the staged video,
+// the cover of the uploaded video.
+func (p *Publisher) setCover(ctx context.Context, video Object, media publish.Media) error {
+	if media.ThumbnailURL == "" {
+		cover, err := extractFrame(ctx, p.cfg.Video.FFmpegPath, media.URL)
+		if err != nil {
+			return err
+		}
+		return p.client.SetVideoCover(video, cover)
+	}
+
+	cover, err := downloader.DownloadFile(media.ThumbnailURL)
+	if err != nil {
+		return err
+	}
+	defer cover.Close()
+
+	return p.client.SetVideoCover(video, cover)
+}
+
+// albumOptions sets the album of the media according to the first album
+// rule matching the item.
+func (p *Publisher) albumOptions(item *source.Item, mediaType string, opts *PostOptions) error {
+	if item == nil {
+		return nil
+	}
+	rule := matchAlbumRule(p.cfg.Albums, item)
+	if rule == nil {
+		return nil
+	}
+
+	var err error
+	switch mediaType {
+	case source.MediaTypeImage:
+		if rule.PhotoAlbum != "" {
+			opts.PhotoAlbumID, err = p.album(AlbumPhoto, rule.PhotoAlbum)
+		}
+	case source.MediaTypeVideo:
+		if rule.VideoAlbum != "" {
+			opts.VideoAlbumID, err = p.album(AlbumVideo, rule.VideoAlbum)
+		}
+	}
+	return err
+}
+
+// album returns the id of the album with the given title, looking it up on VK
+// and creating it when it is not cached yet.
+func (p *Publisher) album(kind, title string) (int, error) {
+	owner := p.client.Owner()
+	id, err := db.AlbumID(kind, owner, title, p.database)
+	if err != nil || id != 0 {
+		return id, err
+	}
+
+	id, err = p.client.FindAlbum(kind, title)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		id, err = p.client.CreateAlbum(kind, title)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, db.SaveAlbum(kind, owner, title, id, p.database)
+}
+
+// forgetAlbums drops the cached albums of the album rule matching the item,
+// so the next attempt looks them up again in case one was deleted on VK.
+func (p *Publisher) forgetAlbums(item *source.Item) {
+	if item == nil {
+		return
+	}
+	rule := matchAlbumRule(p.cfg.Albums, item)
+	if rule == nil {
+		return
+	}
+
+	owner := p.client.Owner()
+	for kind, title := range map[string]string{AlbumPhoto: rule.PhotoAlbum, AlbumVideo: rule.VideoAlbum} {
+		if title == "" {
+			continue
+		}
+		if err := db.DeleteAlbum(kind, owner, title, p.database); err != nil {
+			log.Printf("[publisher:vk] Failed to forget %s album %q: %v\n", kind, title, err)
+		}
+	}
+}
+
+func (p *Publisher) Story(ctx context.Context, story *publish.Story) (*publish.Published, error) {
+	body, err := download(story.Media.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	opts := StoryOptions{
+		LinkText:     story.LinkText,
+		LinkURL:      story.LinkURL,
+		ReplyToStory: story.ReplyToStory,
+		UserIDs:      story.UserIDs,
+	}
+
+	var obj *Object
+	if story.Media.Type == source.MediaTypeImage {
+		obj, err = p.client.UploadStoryPhoto(body, opts)
+	} else {
+		obj, err = p.client.UploadStoryVideo(body, opts)
+	}
+	if Rejected(err) {
+		return nil, publish.Rejected(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &publish.Published{Ref: obj.Ref(), ExpiresAt: obj.ExpiresAt}, nil
+}
+
+func (p *Publisher) Edit(ctx context.Context, ref publish.Ref, caption string) error {
+	obj, err := ParseRef(ref)
+	if err != nil {
+		return err
+	}
+	return p.client.EditCaption(obj, caption)
+}
+
+func (p *Publisher) Delete(ctx context.Context, ref publish.Ref) error {
+	obj, err := ParseRef(ref)
+	if err != nil {
+		return err
+	}
+	return p.client.Delete(obj)
+}
+
+// download opens the staged media.
+func download(url string) (io.ReadCloser, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get staged media: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,74 @@
+package vk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/s-yakubovskiy/inst2vk/pkg/publish"
+)
+
+// Ref returns the publish.Ref of the object. It follows the VK attachment
+// format, e.g. wall-1_2 or video1_2.
+func (o Object) Ref() publish.Ref {
+	return publish.Ref(o.Attachment())
+}
+
+// ParseRef returns the object the ref points to.
+func ParseRef(ref publish.Ref) (Object, error) {
+	s := string(ref)
+	for _, t := range []string{ObjectWall, ObjectVideo, ObjectStory, ObjectPhoto} {
+		rest, ok := strings.CutPrefix(s, t)
+		if !ok {
+			continue
+		}
+		ownerID, id, ok := strings.Cut(rest, "_")
+		if !ok {
+			break
+		}
+		owner, err := strconv.Atoi(ownerID)
+		if err != nil {
+			break
+		}
+		objID, err := strconv.Atoi(id)
+		if err != nil {
+			break
+		}
+		return Object{Type: t, OwnerID: owner, ID: objID}, nil
+	}
+	return Object{}, fmt.Errorf("invalid vk ref %q", ref)
+}
+
+// carouselPrefix marks processing refs of carousels still to be posted.
+const carouselPrefix = "carousel:"
+
+// processingRef returns the ref of uploads VK is still processing, e.g.
+// wall-1_2,video-1_3 for a postponed video, the published object first, or
+// carousel:photo-1_2,video-1_3 for the parts of a carousel.
+func processingRef(carousel bool, uploaded []*Object) publish.Ref {
+	attachments := make([]string, 0, len(uploaded))
+	for _, obj := range uploaded {
+		attachments = append(attachments, obj.Attachment())
+	}
+
+	ref := strings.Join(attachments, ",")
+	if carousel {
+		ref = carouselPrefix + ref
+	}
+	return publish.Ref(ref)
+}
+
+// parseProcessingRef returns the uploads of the processing ref.
+func parseProcessingRef(ref publish.Ref) (bool, []*Object, error) {
+	rest, carousel := strings.CutPrefix(string(ref), carouselPrefix)
+
+	var uploaded []*Object
+	for _, attachment := range strings.Split(rest, ",") {
+		obj, err := ParseRef(publish.Ref(attachment))
+		if err != nil {
+			return false, nil, err
+		}
+		uploaded = append(uploaded, &obj)
+	}
+	return carousel, uploaded, nil
+}
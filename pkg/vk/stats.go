@@ -0,0 +1,151 @@
+package vk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+	"github.com/SevereCloud/vksdk/v2/object"
+)
+
+// Batch sizes of wall.getById and video.get.
+const (
+	postsPerRequest  = 100
+	videosPerRequest = 200
+)
+
+// Stats returns engagement counters (views, likes, reposts, comments) of the
+// published object. Stories are not supported.
+func (c *Client) Stats(obj Object) (map[string]int, error) {
+	switch obj.Type {
+	case ObjectWall:
+		return c.postStats(obj)
+	case ObjectVideo:
+		return c.videoStats(obj)
+	default:
+		return nil, fmt.Errorf("stats are not supported for vk %s", obj.Type)
+	}
+}
+
+// StatsBatch returns the stats of the objects in the order of objs, fetching
+// them with as few execute requests as possible. Stats of objects that were
+// not found or are not supported are nil.
+func (c *Client) StatsBatch(objs []Object) ([]map[string]int, error) {
+	var posts, videos []string
+	for _, obj := range objs {
+		switch obj.Type {
+		case ObjectWall:
+			posts = append(posts, objectKey(obj))
+		case ObjectVideo:
+			videos = append(videos, objectKey(obj))
+		}
+	}
+
+	var calls []call
+	for _, chunk := range chunks(posts, postsPerRequest) {
+		calls = append(calls, call{method: "wall.getById", params: api.Params{"posts": strings.Join(chunk, ",")}})
+	}
+	postCalls := len(calls)
+	for _, chunk := range chunks(videos, videosPerRequest) {
+		calls = append(calls, call{method: "video.get", params: api.Params{"videos": strings.Join(chunk, ",")}})
+	}
+
+	responses, err := c.execute(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	// found is keyed by the object type and key
+	found := make(map[string]map[string]int, len(objs))
+	for i, raw := range responses {
+		// a failed call returns false, its objects are reported as not found
+		if i < postCalls {
+			var resp api.WallGetByIDResponse
+			if json.Unmarshal(raw, &resp) != nil {
+				continue
+			}
+			for _, post := range resp {
+				found[ObjectWall+objectKey(Object{OwnerID: post.OwnerID, ID: post.ID})] = postCounters(post)
+			}
+		} else {
+			var resp api.VideoGetResponse
+			if json.Unmarshal(raw, &resp) != nil {
+				continue
+			}
+			for _, video := range resp.Items {
+				found[ObjectVideo+objectKey(Object{OwnerID: video.OwnerID, ID: video.ID})] = videoCounters(video)
+			}
+		}
+	}
+
+	stats := make([]map[string]int, len(objs))
+	for i, obj := range objs {
+		stats[i] = found[obj.Type+objectKey(obj)]
+	}
+	return stats, nil
+}
+
+func (c *Client) postStats(obj Object) (map[string]int, error) {
+	posts, err := c.vk.WallGetByID(api.Params{
+		"posts": objectKey(obj),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("vk post %s not found", objectKey(obj))
+	}
+
+	return postCounters(posts[0]), nil
+}
+
+func (c *Client) videoStats(obj Object) (map[string]int, error) {
+	resp, err := c.vk.VideoGet(api.Params{
+		"videos": objectKey(obj),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, fmt.Errorf("vk video %s not found", objectKey(obj))
+	}
+
+	return videoCounters(resp.Items[0]), nil
+}
+
+func postCounters(post object.WallWallpost) map[string]int {
+	return map[string]int{
+		"views":    post.Views.Count,
+		"likes":    post.Likes.Count,
+		"reposts":  post.Reposts.Count,
+		"comments": post.Comments.Count,
+	}
+}
+
+func videoCounters(video object.VideoVideo) map[string]int {
+	return map[string]int{
+		"views":    video.Views,
+		"likes":    video.Likes.Count,
+		"reposts":  video.Reposts.Count,
+		"comments": video.Comments,
+	}
+}
+
+// objectKey returns the "<owner_id>_<id>" form VK uses to reference posts
+// and videos.
+func objectKey(obj Object) string {
+	return fmt.Sprintf("%d_%d", obj.OwnerID, obj.ID)
+}
+
+func chunks(ids []string, size int) [][]string {
+	var out [][]string
+	for len(ids) > size {
+		out = append(out, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		out = append(out, ids)
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package vk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v2/api"
+)
+
+// userScopes are the access rights bits of user tokens.
+var userScopes = []struct {
+	name string
+	bit  int
+}{
+	{"photos", 1 << 2},
+	{"video", 1 << 4},
+	{"stories", 1 << 6},
+	{"wall", 1 << 13},
+	{"groups", 1 << 18},
+}
+
+// TokenInfo describes the token of the client.
+type TokenInfo struct {
+	// UserID is set for user tokens
+	UserID int `json:"user_id,omitempty"`
+	// GroupID is set for community tokens
+	GroupID int      `json:"group_id,omitempty"`
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	// Missing lists the scopes publishing needs but the token lacks
+	Missing []string `json:"missing,omitempty"`
+}
+
+// requiredScopes returns the scopes publishing needs with a user token.
+func (c *Client) requiredScopes() []string {
+	scopes := []string{"photos", "video", "wall", "stories"}
+	if c.groupID != 0 {
+		scopes = append(scopes, "groups")
+	}
+	return scopes
+}
+
+// Validate checks that the token works and has the scopes needed for
+// publishing. The token info is returned even if scopes are missing.
+func (c *Client) Validate() (*TokenInfo, error) {
+	info, err := c.tokenInfo()
+	if err != nil {
+		return nil, fmt.Errorf("vk token is invalid: %w", err)
+	}
+
+	// community tokens never get video or wall rights, VK only grants them
+	// photos, stories, messages, docs, app_widget and manage
+	if info.GroupID != 0 {
+		return info, fmt.Errorf("vk token of community %s can't call wall.post or video.save, use a user token of a community admin", info.Name)
+	}
+
+	granted := make(map[string]bool, len(info.Scopes))
+	for _, scope := range info.Scopes {
+		granted[scope] = true
+	}
+	for _, scope := range c.requiredScopes() {
+		if !granted[scope] {
+			info.Missing = append(info.Missing, scope)
+		}
+	}
+	if len(info.Missing) > 0 {
+		return info, fmt.Errorf("vk token of %s lacks scopes: %s", info.Name, strings.Join(info.Missing, ","))
+	}
+
+	// user tokens publish to communities as their admins
+	if c.groupID != 0 && info.UserID != 0 {
+		groups, err := c.vk.GroupsGetByID(api.Params{"group_id": c.groupID})
+		if err != nil {
+			return info, fmt.Errorf("get vk community %d: %w", c.groupID, err)
+		}
+		if len(groups) == 0 || !bool(groups[0].IsAdmin) {
+			return info, fmt.Errorf("vk user %s is not an admin of community %d", info.Name, c.groupID)
+		}
+	}
+
+	return info, nil
+}
+
+// tokenInfo tells user tokens from community tokens, users.get returns
+// nobody or fails for the latter.
+func (c *Client) tokenInfo() (*TokenInfo, error) {
+	users, err := c.vk.UsersGet(api.Params{})
+	if KindOf(err) == ErrorKindAuth {
+		return nil, err
+	}
+	if err == nil && len(users) > 0 {
+		user := users[0]
+		mask, err := c.vk.AccountGetAppPermissions(api.Params{"user_id": user.ID})
+		if err != nil {
+			return nil, err
+		}
+
+		info := &TokenInfo{UserID: user.ID, Name: strings.TrimSpace(user.FirstName + " " + user.LastName)}
+		for _, scope := range userScopes {
+			if mask&scope.bit != 0 {
+				info.Scopes = append(info.Scopes, scope.name)
+			}
+		}
+		return info, nil
+	}
+
+	groups, err := c.vk.GroupsGetByID(api.Params{})
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("vk token belongs to neither a user nor a community")
+	}
+	perms, err := c.vk.GroupsGetTokenPermissions(api.Params{})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TokenInfo{GroupID: groups[0].ID, Name: groups[0].Name}
+	for _, perm := range perms.Permissions {
+		info.Scopes = append(info.Scopes, perm.Name)
+	}
+	return info, nil
+}
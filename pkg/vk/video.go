@@ -0,0 +1,65 @@
+package vk
+
+import "github.com/s-yakubovskiy/inst2vk/pkg/config"
+
+// VideoSettings are the video.save options of uploaded videos.
+type VideoSettings struct {
+	PrivacyView    []string
+	PrivacyComment []string
+	NoComments     bool
+	Repeat         bool
+	Compression    bool
+	// Wallpost also publishes the video on the wall
+	Wallpost bool
+}
+
+// VideoOverrides change some of the VideoSettings, nil fields keep the
+// configured value.
+type VideoOverrides struct {
+	PrivacyView    []string `json:"privacy_view,omitempty"`
+	PrivacyComment []string `json:"privacy_comment,omitempty"`
+	NoComments     *bool    `json:"no_comments,omitempty"`
+	Repeat         *bool    `json:"repeat,omitempty"`
+	Compression    *bool    `json:"compression,omitempty"`
+	Wallpost       *bool    `json:"wallpost,omitempty"`
+}
+
+// newVideoSettings returns the configured settings, repeat, compression and
+// wallpost are on unless disabled.
+func newVideoSettings(cfg config.VideoConfig) VideoSettings {
+	return VideoSettings{
+		PrivacyView:    cfg.PrivacyView,
+		PrivacyComment: cfg.PrivacyComment,
+		NoComments:     cfg.NoComments,
+		Repeat:         boolOr(cfg.Repeat, true),
+		Compression:    boolOr(cfg.Compression, true),
+		Wallpost:       boolOr(cfg.Wallpost, true),
+	}
+}
+
+// Apply returns the settings with the overrides applied.
+func (s VideoSettings) Apply(o VideoOverrides) VideoSettings {
+	if o.PrivacyView != nil {
+		s.PrivacyView = o.PrivacyView
+	}
+	if o.PrivacyComment != nil {
+		s.PrivacyComment = o.PrivacyComment
+	}
+	s.NoComments = boolOr(o.NoComments, s.NoComments)
+	s.Repeat = boolOr(o.Repeat, s.Repeat)
+	s.Compression = boolOr(o.Compression, s.Compression)
+	s.Wallpost = boolOr(o.Wallpost, s.Wallpost)
+	return s
+}
+
+// VideoSettings returns the configured video settings.
+func (c *Client) VideoSettings() VideoSettings {
+	return c.video
+}
+
+func boolOr(v *bool, fallback bool) bool {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
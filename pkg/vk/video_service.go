@@ -8,10 +8,12 @@ import (
 
 type VideoService struct {
 	Client *Client
+	// Token is the token info validated on startup
+	Token *TokenInfo
 }
 
-func NewVideoService(client *Client) *VideoService {
-	return &VideoService{Client: client}
+func NewVideoService(client *Client, token *TokenInfo) *VideoService {
+	return &VideoService{Client: client, Token: token}
 }
 
 func (s *VideoService) UploadHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,11 +39,49 @@ func (s *VideoService) UploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.Client.UploadVideo(params.Name, params.Description, resp.Body)
+	settings := s.Client.VideoSettings().Apply(params.VideoOverrides)
+	_, err = s.Client.UploadVideo(params.Name, params.Description, resp.Body, PostOptions{Video: &settings})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
 	fmt.Fprintf(w, "Video uploaded successfully")
 }
+
+// errorStatus maps VK errors to the status of the upload response.
+func errorStatus(err error) int {
+	switch KindOf(err) {
+	case ErrorKindTooManyRequests, ErrorKindFlood, ErrorKindCaptcha:
+		return http.StatusTooManyRequests
+	case ErrorKindAuth, ErrorKindAccessDenied:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Health is the response of the health endpoint.
+type Health struct {
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Token  *TokenInfo `json:"token,omitempty"`
+}
+
+// HealthHandler reports the token validated on startup and whether requests
+// are paused after VK errors. It makes no VK requests, so probes can't run
+// into rate limits or pause the client.
+func (s *VideoService) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	health := Health{Status: "ok", Token: s.Token}
+	status := http.StatusOK
+
+	if err := s.Client.Paused(); err != nil {
+		health.Status = "error"
+		health.Error = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
+}